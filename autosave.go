@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+type autosaveChangedMsg struct {
+	interval time.Duration
+}
+
+type autosaveTickMsg struct {
+	id int
+}
+
+//
+// A backup loaded with :recover.  It replaces the canvas like any other
+// load, but belongs to the original file and still needs saving there.
+//
+type recoveredMsg struct {
+	canvasLoadedMsg
+	filename string
+}
+
+//
+// Where a document is backed up to: next to its file, or for one that's
+// never been saved or is piped, somewhere temporary that's unique to this
+// session.
+//
+func backupPath(filename string, tab int) string {
+	if filename != "" && filename != stdioName {
+		return filename + ".bak"
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gopnik-%d-%d.bak", os.Getpid(), tab + 1))
+}
+
+//
+// Ticks every interval until the interval changes.  Like status messages,
+// ticks from before the latest change are told apart by their id.
+//
+func (m *model) scheduleAutosave() tea.Cmd {
+	m.autosaveID++
+	return m.autosaveTick()
+}
+
+//
+// Nothing, when autosave is off or headless, where apply would otherwise
+// wait on the tick forever.
+//
+func (m model) autosaveTick() tea.Cmd {
+	if m.autosaveInterval <= 0 || m.headless {
+		return nil
+	}
+	id := m.autosaveID
+	return tea.Tick(m.autosaveInterval, func(time.Time) tea.Msg {
+		return autosaveTickMsg{id}
+	})
+}
+
+//
+// Back up every tab that's changed since it was last saved or backed up.
+// The layers are copied here, since drawing carries on while the backups
+// are written.
+//
+func (m *model) backup() tea.Cmd {
+	m.syncTab()
+	var cmds []tea.Cmd
+	for i, doc := range m.tabs {
+		if !doc.dirty || !doc.backupStale {
+			continue
+		}
+		m.tabs[i].backupStale = false
+		path := backupPath(doc.filename, i)
+		layers, width, height, marks := canvas.CloneLayers(doc.layers), doc.width, doc.height, doc.marks
+		cmds = append(cmds, func() tea.Msg {
+			fout, err := os.Create(path)
+			if err != nil {
+				return errMsg{fmt.Errorf("autosave: %w", err)}
+			}
+			defer fout.Close()
+			if err := canvas.Save(layers, width, height, marks, false, fout); err != nil {
+				return errMsg{fmt.Errorf("autosave: %w", err)}
+			}
+			debugf("backed up to %s", path)
+			return nil
+		})
+	}
+	m.document = m.tabs[m.activeTab]
+	return tea.Batch(cmds...)
+}
+
+//
+// Point out a backup of filename that's newer than filename itself, which
+// is what's left behind when gopnik doesn't exit cleanly.
+//
+func checkBackup(filename string) tea.Msg {
+	backup, err := os.Stat(backupPath(filename, 0))
+	if err != nil {
+		return nil
+	}
+	if original, err := os.Stat(filename); err == nil && !backup.ModTime().After(original.ModTime()) {
+		return nil
+	}
+	return statusMsg{fmt.Sprintf("%s has a newer backup, use :recover to load it", filename)}
+}
+
+func recoverBackup(filename string, tab int) tea.Msg {
+	msg := loadFile(backupPath(filename, tab))
+	if loaded, ok := msg.(canvasLoadedMsg); ok {
+		return recoveredMsg{loaded, filename}
+	}
+	return msg
+}
@@ -0,0 +1,159 @@
+package main
+
+//
+// Directions that a box-drawing glyph connects to, as a bitmask.
+//
+const (
+	connectUp = 1 << iota
+	connectRight
+	connectDown
+	connectLeft
+)
+
+//
+// The light box-drawing glyph for each combination of connections.
+//
+var boxGlyphs = [16]rune{
+	0: ' ',
+	connectUp: '╵',
+	connectRight: '╶',
+	connectDown: '╷',
+	connectLeft: '╴',
+	connectUp | connectDown: '│',
+	connectLeft | connectRight: '─',
+	connectRight | connectDown: '┌',
+	connectLeft | connectDown: '┐',
+	connectUp | connectRight: '└',
+	connectUp | connectLeft: '┘',
+	connectUp | connectRight | connectDown: '├',
+	connectUp | connectLeft | connectDown: '┤',
+	connectLeft | connectRight | connectDown: '┬',
+	connectLeft | connectRight | connectUp: '┴',
+	connectUp | connectRight | connectDown | connectLeft: '┼',
+}
+
+var boxMasks = func() map[rune]int {
+	masks := map[rune]int{}
+	for mask, r := range boxGlyphs {
+		if mask != 0 {
+			masks[r] = mask
+		}
+	}
+	return masks
+}()
+
+func boxMask(r rune) (int, bool) {
+	mask, ok := boxMasks[r]
+	return mask, ok
+}
+
+var boxNeighbors = []struct {
+	dx, dy int
+	dir, opposite int
+}{
+	{0, -1, connectUp, connectDown},
+	{1, 0, connectRight, connectLeft},
+	{0, 1, connectDown, connectUp},
+	{-1, 0, connectLeft, connectRight},
+}
+
+//
+// Join the box-drawing glyph at (x, y) with its neighbors.  The cell
+// connects towards every neighbor that reaches towards it, and every
+// neighbor it reaches towards connects back, so e.g. placing ─ to the
+// left of │ turns the latter into ┤.  Connections are only ever added.
+//
+func autoConnect(canvas Canvas, x, y int) {
+	at := func(x, y int) (int, bool) {
+		if !canvas.In(x, y) {
+			return 0, false
+		}
+		return boxMask(canvas.At(x, y).R)
+	}
+	mask, ok := at(x, y)
+	if !ok {
+		return
+	}
+	for _, n := range boxNeighbors {
+		if neighbor, ok := at(x + n.dx, y + n.dy); ok && neighbor & n.opposite != 0 {
+			mask |= n.dir
+		}
+	}
+	canvas.Cells()[y][x].R = boxGlyphs[mask]
+
+	for _, n := range boxNeighbors {
+		if neighbor, ok := at(x + n.dx, y + n.dy); ok && mask & n.dir != 0 {
+			canvas.Cells()[y + n.dy][x + n.dx].R = boxGlyphs[neighbor | n.opposite]
+		}
+	}
+}
+
+//
+// Draw a horizontal or vertical line in box-drawing glyphs, joining it to
+// any lines it crosses or ends on: ending on │ from the left makes ┤, and
+// crossing it makes ┼.  Returns false, drawing nothing, unless brush is a
+// box-drawing glyph and the line is straight.
+//
+func drawBoxLine(canvas Canvas, x0, y0, x1, y1 int, brush pixel) bool {
+	if _, ok := boxMask(brush.R); !ok || (x0 != x1 && y0 != y1) || (x0 == x1 && y0 == y1) {
+		return false
+	}
+	//
+	// Go from the smaller end, with forward being the direction of travel.
+	//
+	forward, backward := connectRight, connectLeft
+	dx, dy, n := 1, 0, max(x0, x1) - min(x0, x1)
+	if x0 == x1 {
+		forward, backward = connectDown, connectUp
+		dx, dy, n = 0, 1, max(y0, y1) - min(y0, y1)
+	}
+	x, y := min(x0, x1), min(y0, y1)
+	for i := 0; i <= n; i, x, y = i + 1, x + dx, y + dy {
+		if !canvas.In(x, y) {
+			continue
+		}
+		mask := forward | backward
+		existing, joined := boxMask(canvas.At(x, y).R)
+		if joined && i == 0 {
+			mask = forward
+		} else if joined && i == n {
+			mask = backward
+		}
+		canvas.Set(x, y, brush.WithRune(boxGlyphs[mask | existing]))
+	}
+	return true
+}
+
+//
+// Call f for every box-drawing cell of canvas with a dangling connection,
+// one towards a neighbor (or the edge) that doesn't connect back, like the
+// end of a table border that stops short of its corner.
+//
+func eachDangling(canvas Canvas, f func(x, y int)) {
+	for y := range canvas.Cells() {
+		for x, p := range canvas.Cells()[y] {
+			mask, ok := boxMask(p.R)
+			if !ok {
+				continue
+			}
+			for _, n := range boxNeighbors {
+				neighbor, _ := boxMask(canvas.At(x + n.dx, y + n.dy).R)
+				if mask & n.dir != 0 && neighbor & n.opposite == 0 {
+					f(x, y)
+					break
+				}
+			}
+		}
+	}
+}
+
+//
+// Dangling connections are shown on a red background.
+//
+const danglingColor color = "1"
+
+func markDangling(canvas Canvas) {
+	eachDangling(canvas, func(x, y int) {
+		canvas.Cells()[y][x].BG = danglingColor
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEachDangling(t *testing.T) {
+	tests := []struct {
+		name string
+		c Canvas
+		want [][2]int
+	}{
+		{"closed", canvasOf("┌─┐", "└─┘"), nil},
+		{"open", canvasOf("┌─ ", "│ x", "   "), [][2]int{{1, 0}, {0, 1}}},
+		{"at the edge", canvasOf("──"), [][2]int{{0, 0}, {1, 0}}},
+		{"not connecting back", canvasOf("─│"), [][2]int{{0, 0}, {1, 0}}},
+	}
+	for _, test := range tests {
+		var got [][2]int
+		eachDangling(test.c, func(x, y int) {
+			got = append(got, [2]int{x, y})
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+type brushShape int
+
+const (
+	brushSquare brushShape = iota
+	brushCircle
+)
+
+const maxBrushSize = 32
+
+//
+// The argument to :brush: an optional slot number, 1 for the primary brush
+// and 2 for the secondary, then the character, e.g. "2 U+2588" for a full
+// block in the secondary brush.
+//
+func parseBrushArg(arg string) (slot int, r rune, err error) {
+	slot = 1
+	if args := strings.SplitN(arg, " ", 2); len(args) == 2 && (args[0] == "1" || args[0] == "2") {
+		slot, arg = int(args[0][0] - '0'), strings.TrimSpace(args[1])
+	}
+	r, err = parseBrushRune(arg)
+	return slot, r, err
+}
+
+//
+// A character, its code point, or its name, e.g. "█", "U+2588" or "full
+// block".
+//
+func parseBrushRune(s string) (rune, error) {
+	r, _ := utf8.DecodeRuneInString(s)
+	if lower := strings.ToLower(s); len(s) > 2 && (strings.HasPrefix(lower, "\\u") || strings.HasPrefix(lower, "u+")) {
+		codePoint, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("bad code point %q", s)
+		}
+		r = rune(codePoint)
+	} else if utf8.RuneCountInString(s) > 1 {
+		return lookupGlyph(s)
+	}
+	if r > unicode.MaxRune || (r >= 0xd800 && r <= 0xdfff) {
+		return 0, fmt.Errorf("U+%04X isn't a character", r)
+	} else if !unicode.IsGraphic(r) {
+		return 0, fmt.Errorf("U+%04X isn't printable", r)
+	} else if runewidth.RuneWidth(r) == 0 {
+		//
+		// Combining marks take up no column, so painting with one would
+		// pull the rest of the row left.
+		//
+		return 0, fmt.Errorf("U+%04X takes up no space", r)
+	}
+	return r, nil
+}
+
+//
+// What the :brush being typed would paint with, shown after the command
+// line so that code points can be checked before they're used.
+//
+func brushPreview(command string) string {
+	verb, arg, _ := strings.Cut(command, " ")
+	if arg = strings.TrimSpace(arg); (verb != "b" && verb != "brush") || arg == "" {
+		return ""
+	}
+	_, r, err := parseBrushArg(arg)
+	if err != nil {
+		return "  (" + err.Error() + ")"
+	}
+	return fmt.Sprintf("  %c U+%04X", r, r)
+}
+
+func (s brushShape) String() string {
+	if s == brushCircle {
+		return "circle"
+	}
+	return "square"
+}
+
+func parseBrushShape(s string) (brushShape, error) {
+	switch s {
+	case "square":
+		return brushSquare, nil
+	case "circle":
+		return brushCircle, nil
+	}
+	return brushSquare, fmt.Errorf("bad brush shape %q: expected square or circle", s)
+}
+
+//
+// Returns the offsets, relative to the cursor, of the cells covered by a
+// brush of the given size and shape.  Even-sized brushes extend one cell
+// further to the right and bottom of the cursor.
+//
+func brushOffsets(size int, shape brushShape) [][2]int {
+	if size < 1 {
+		size = 1
+	}
+	lo, hi := -(size - 1) / 2, size / 2
+	center := float64(lo + hi) / 2
+	radius := float64(size) / 2
+
+	var offsets [][2]int
+	for dy := lo; dy <= hi; dy++ {
+		for dx := lo; dx <= hi; dx++ {
+			if shape == brushCircle {
+				ex, ey := float64(dx) - center, float64(dy) - center
+				if ex * ex + ey * ey > radius * radius - radius / 2 {
+					continue
+				}
+			}
+			offsets = append(offsets, [2]int{dx, dy})
+		}
+	}
+	return offsets
+}
+
+//
+// The left mouse button paints with the primary brush, the right one with
+// the secondary brush, unless erasing.  Other buttons don't paint at all.
+//
+func (m model) mouseBrush(button tea.MouseButton) (pixel, bool) {
+	if m.erasing && (button == tea.MouseButtonLeft || button == tea.MouseButtonRight) {
+		return m.eraser(), true
+	}
+	switch button {
+	case tea.MouseButtonLeft:
+		return m.brushPrimary, true
+	case tea.MouseButtonRight:
+		return m.brushSecondary, true
+	}
+	return pixel{}, false
+}
+
+//
+// Paint the brush centered on (x, y), clipped to the canvas (or wrapped
+// around it), along with its reflections if mirroring is on.
+//
+func (m *model) stamp(x, y int, brush pixel) {
+	span := 1
+	if canvas.IsWide(brush.R) {
+		span = 2
+	}
+	for _, offset := range brushOffsets(m.brushSize, m.brushShape) {
+		px, py := x + offset[0], y + offset[1]
+		if m.wrap {
+			//
+			// Tile the canvas, so that the parts of the brush that go past
+			// an edge come back in on the opposite one.
+			//
+			px = ((px % m.width) + m.width) % m.width
+			py = ((py % m.height) + m.height) % m.height
+		}
+		for _, p := range mirrorPoints(px, py, m.width, m.height, span, m.mirror) {
+			m.canvas().Set(p[0], p[1], m.inked(p[0], p[1], brush))
+			if m.autoConnect {
+				autoConnect(m.canvas(), p[0], p[1])
+			}
+		}
+	}
+}
+
+//
+// What painting brush at (x, y) leaves with thin ink: a shade brush builds
+// on the shade that's there, with anything that isn't a shade taken for a
+// blank.  Other brushes, and any brush with full ink, go on as they are.
+//
+func (m model) inked(x, y int, brush pixel) pixel {
+	b := slices.Index(defaultRamp, brush.R)
+	if m.ink <= 0 || m.ink >= 1 || b < 0 {
+		return brush
+	}
+	existing := max(slices.Index(defaultRamp, m.canvas().At(x, y).R), 0)
+	return brush.WithRune(defaultRamp[blendShade(existing, b, len(defaultRamp), m.ink)])
+}
+
+//
+// What erasing paints: transparency, so that the layers below show
+// through, or a blank on the bottom layer, which has nothing below it.
+//
+func (m model) eraser() pixel {
+	if m.activeLayer > 0 {
+		return transparent
+	}
+	return pixel{R: ' '}
+}
+
+//
+// Grow or shrink the brush, within 1 to maxBrushSize.
+//
+func (m *model) resizeBrush(delta int) {
+	m.brushSize = clamp(m.brushSize + delta, 1, maxBrushSize)
+}
+
+//
+// Scrolling up grows the brush and down shrinks it.  With ctrl or shift
+// held, it steps through the shade ramp instead: up towards the light end,
+// like the lighter key.
+//
+func (m *model) wheel(msg tea.MouseMsg) {
+	delta := 0
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		delta = 1
+	case tea.MouseButtonWheelDown:
+		delta = -1
+	}
+	if delta == 0 {
+		return
+	}
+	if msg.Ctrl || msg.Shift {
+		m.cycleShade(-delta)
+	} else {
+		m.resizeBrush(delta)
+	}
+}
+
+//
+// How many brushes the recent key and :brushes remember.
+//
+const maxRecentBrushes = 8
+
+//
+// Put p at the front of the recent brushes, moving it there if it's
+// already among them.
+//
+func (m *model) rememberBrush(p pixel) {
+	recent := []pixel{p}
+	for _, q := range m.recentBrushes {
+		if q != p && len(recent) < maxRecentBrushes {
+			recent = append(recent, q)
+		}
+	}
+	m.recentBrushes = recent
+}
+
+//
+// Step the primary brush back through the recent brushes, wrapping around
+// to the newest after the oldest.  Stepping doesn't reorder them, so
+// pressing the key repeatedly goes further back each time.  False if
+// there's nothing else to step to.
+//
+func (m *model) cycleRecentBrush() bool {
+	n := len(m.recentBrushes)
+	if n == 0 || (n == 1 && m.recentBrushes[0] == m.brushPrimary) {
+		return false
+	}
+	if m.recentIndex >= n || m.recentBrushes[m.recentIndex] != m.brushPrimary {
+		m.recentIndex = slices.Index(m.recentBrushes, m.brushPrimary)
+	}
+	m.recentIndex = (m.recentIndex + 1) % n
+	m.brushPrimary = m.recentBrushes[m.recentIndex]
+	return true
+}
+
+//
+// The recent brushes with their code points, newest first, e.g.
+// "█ U+2588  # U+0023".
+//
+func listBrushes(m model) tea.Msg {
+	if len(m.recentBrushes) == 0 {
+		return statusMsg{"no recent brushes, see :brush"}
+	}
+	var brushes []string
+	for _, p := range m.recentBrushes {
+		brushes = append(brushes, fmt.Sprintf("%c U+%04X", p.R, p.R))
+	}
+	return statusMsg{strings.Join(brushes, "  ")}
+}
+
+var defaultShadeRamp = []rune(" .:-=+*#%@")
+
+//
+// Step the primary brush through the shade ramp, from light to dark for a
+// positive delta, wrapping around at the ends.  If the brush is somewhere
+// in the ramp, that's where stepping starts.
+//
+func (m *model) cycleShade(delta int) {
+	n := len(m.shadeRamp)
+	if n == 0 {
+		return
+	}
+	if m.shadeIndex >= n || m.shadeRamp[m.shadeIndex] != m.brushPrimary.R {
+		//
+		// Off the ramp, the first step goes to an end of it.
+		//
+		m.shadeIndex = -1
+		if delta < 0 {
+			m.shadeIndex = 0
+		}
+		for i, r := range m.shadeRamp {
+			if r == m.brushPrimary.R {
+				m.shadeIndex = i
+			}
+		}
+	}
+	m.shadeIndex = ((m.shadeIndex + delta) % n + n) % n
+	m.brushPrimary.R = m.shadeRamp[m.shadeIndex]
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestParseBrushRune(t *testing.T) {
+	tests := []struct {
+		s string
+		want rune
+		err string
+	}{
+		{"#", '#', ""},
+		{"█", '█', ""},
+		{"U+2588", '█', ""},
+		{"u+2588", '█', ""},
+		{"\\u2588", '█', ""},
+		{"full block", '█', ""},
+		{"漢", '漢', ""},
+		{"U+XYZ", 0, "bad code point \"U+XYZ\""},
+		{"U+D800", 0, "U+D800 isn't a character"},
+		{"U+0007", 0, "U+0007 isn't printable"},
+		{"U+0301", 0, "U+0301 takes up no space"},
+		{"U+20DD", 0, "U+20DD takes up no space"},
+		{"́", 0, "U+0301 takes up no space"},
+	}
+	for _, test := range tests {
+		got, err := parseBrushRune(test.s)
+		if got != test.want || (err == nil) != (test.err == "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%q: got %q, %v, want %q, %s", test.s, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestRecentBrushes(t *testing.T) {
+	m := run(t, testModel(2, 2), "brush a", "brush b", "brush c", "brush a")
+	if got, want := listBrushes(m), (statusMsg{"a U+0061  c U+0063  b U+0062  # U+0023"}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, want := range "cb#a" {
+		if !m.cycleRecentBrush() || m.brushPrimary.R != want {
+			t.Errorf("stepped to %c, want %c", m.brushPrimary.R, want)
+		}
+	}
+
+	for i := 0; i < maxRecentBrushes + 2; i++ {
+		m.rememberBrush(pixel{R: rune('0' + i)})
+	}
+	if n := len(m.recentBrushes); n != maxRecentBrushes {
+		t.Errorf("remembered %d brushes, want %d", n, maxRecentBrushes)
+	}
+
+	m = testModel(2, 2)
+	if m.cycleRecentBrush() {
+		t.Errorf("stepped with no recent brushes")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "github.com/mpenkov/gopnik/canvas"
+
+//
+// The drawing model lives in package canvas, so that it can be used
+// without the editor.  These are the names the editor knows it by.
+//
+type (
+	Canvas = canvas.Canvas
+	pixel = canvas.Pixel
+	color = canvas.Color
+	attrs = canvas.Attrs
+	layer = canvas.Layer
+	header = canvas.Header
+	point = canvas.Point
+)
+
+const (
+	noColor = canvas.NoColor
+	attrBold = canvas.Bold
+	attrUnderline = canvas.Underline
+	attrBlink = canvas.Blink
+	attrReverse = canvas.Reverse
+	maxCanvasWidth = canvas.MaxWidth
+	maxCanvasHeight = canvas.MaxHeight
+)
+
+var (
+	transparent = canvas.Transparent
+	padding = canvas.Padding
+)
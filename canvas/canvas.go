@@ -0,0 +1,269 @@
+//
+// Package canvas is the drawing model behind gopnik: canvases of styled
+// pixels, layers, the primitives that draw on them, and the file format
+// that they're saved in.  It has nothing to do with the terminal, so other
+// programs can read, draw and write gopnik files with it.
+//
+package canvas
+
+//
+// A grid of pixels that knows its own size.  Every row is exactly width
+// cells long, and At and Set check bounds, so nothing that's handed a
+// canvas needs to be handed its size too, or to check it.
+//
+// Like a slice, a copy of a Canvas shares its cells, so setting a pixel of
+// one sets it in the other.  Clone makes a separate copy.
+//
+type Canvas struct {
+	width, height int
+	cells [][]Pixel
+}
+
+//
+// A width x height canvas with every cell set to fill.
+//
+func Filled(width, height int, fill Pixel) Canvas {
+	cells := make([][]Pixel, height)
+	for y := range cells {
+		cells[y] = make([]Pixel, width)
+		for x := range cells[y] {
+			cells[y][x] = fill
+		}
+	}
+	return Canvas{width, height, cells}
+}
+
+//
+// A blank canvas, which is all spaces.
+//
+func New(width, height int) Canvas {
+	return Filled(width, height, Pixel{R: ' '})
+}
+
+//
+// A width x height canvas of rows, which are cut short (as is the list of
+// them) when they're too long and filled out with fill when they're too
+// short.  Rows that are already the right length are used as they are.
+//
+func Of(rows [][]Pixel, width, height int, fill Pixel) Canvas {
+	if len(rows) > height {
+		rows = rows[:height]
+	}
+	for len(rows) < height {
+		rows = append(rows, nil)
+	}
+	for y, row := range rows {
+		if len(row) > width {
+			rows[y] = row[:width]
+		}
+		for len(rows[y]) < width {
+			rows[y] = append(rows[y], fill)
+		}
+	}
+	return Canvas{width, height, rows}
+}
+
+func (c Canvas) Bounds() (width, height int) {
+	return c.width, c.height
+}
+
+func (c Canvas) Width() int {
+	return c.width
+}
+
+func (c Canvas) Height() int {
+	return c.height
+}
+
+//
+// The rows of the canvas, which share their pixels with it.  Writing to
+// them skips what Set does to keep wide glyphs whole.
+//
+func (c Canvas) Cells() [][]Pixel {
+	return c.cells
+}
+
+//
+// Whether (x, y) is a cell of the canvas.
+//
+func (c Canvas) In(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.width && y < c.height
+}
+
+//
+// The pixel at (x, y), which is transparent off the canvas.
+//
+func (c Canvas) At(x, y int) Pixel {
+	if !c.In(x, y) {
+		return Transparent
+	}
+	return c.cells[y][x]
+}
+
+//
+// Put p at (x, y) if that's on the canvas.  This keeps wide glyphs and their
+// padding together: overwriting either half of a wide glyph blanks the
+// other half, and a wide glyph that would overflow the row is dropped.
+//
+func (c Canvas) Set(x, y int, p Pixel) {
+	if !c.In(x, y) || p == Padding {
+		return
+	}
+	row := c.cells[y]
+	wide := IsWide(p.R)
+	if wide && x + 1 >= len(row) {
+		return
+	}
+	if owner := CellOwner(row, x); owner != x {
+		row[owner] = row[owner].WithRune(' ')
+	}
+	if IsWide(row[x].R) && x + 1 < len(row) && row[x+1] == Padding {
+		row[x+1] = Pixel{R: ' '}
+	}
+	if wide {
+		if IsWide(row[x+1].R) && x + 2 < len(row) && row[x+2] == Padding {
+			row[x+2] = Pixel{R: ' '}
+		}
+		row[x+1] = Padding
+	}
+	row[x] = p
+}
+
+//
+// Set every cell to p, as is: a wide p isn't given any padding.
+//
+func (c Canvas) Fill(p Pixel) {
+	for y := range c.cells {
+		for x := range c.cells[y] {
+			c.cells[y][x] = p
+		}
+	}
+}
+
+//
+// A width x height copy of the canvas with the content moved right by dx
+// and down by dy.  New cells are set to fill, and anything that ends up
+// beyond the new size is cropped.
+//
+func (c Canvas) Resize(width, height, dx, dy int, fill Pixel) Canvas {
+	out := Filled(width, height, fill)
+	for y := range out.cells {
+		for x := range out.cells[y] {
+			if c.In(x - dx, y - dy) {
+				out.cells[y][x] = c.cells[y - dy][x - dx]
+			}
+		}
+	}
+	return out
+}
+
+//
+// A copy that shares nothing with the original, e.g. for undo history.
+//
+func (c Canvas) Clone() Canvas {
+	return Canvas{c.width, c.height, CloneCells(c.cells)}
+}
+
+//
+// A copy of src with rows of its own.  Copying the outer slice alone would
+// leave both sharing every row.
+//
+func CloneCells(src [][]Pixel) [][]Pixel {
+	out := make([][]Pixel, len(src))
+	for y, row := range src {
+		out[y] = append([]Pixel(nil), row...)
+	}
+	return out
+}
+
+type Point struct {
+	X, Y int
+}
+
+//
+// A copy of c with a row of fill inserted before row y, so that y ==
+// height adds one at the bottom.
+//
+func (c Canvas) InsertRow(y int, fill Pixel) Canvas {
+	row := Filled(c.width, 1, fill).cells[0]
+	cells := append(append(append([][]Pixel(nil), c.cells[:y]...), row), c.cells[y:]...)
+	return Canvas{c.width, c.height + 1, cells}.Clone()
+}
+
+func (c Canvas) DeleteRow(y int) Canvas {
+	cells := append(append([][]Pixel(nil), c.cells[:y]...), c.cells[y+1:]...)
+	return Canvas{c.width, c.height - 1, cells}.Clone()
+}
+
+//
+// A copy of c with a column of fill inserted before column x, so that x ==
+// width adds one on the right.
+//
+func (c Canvas) InsertColumn(x int, fill Pixel) Canvas {
+	out := c.Resize(c.width + 1, c.height, 0, 0, fill)
+	for y, row := range out.cells {
+		copy(row[x+1:], c.cells[y][x:])
+		row[x] = fill
+		mendWide(row)
+	}
+	return out
+}
+
+func (c Canvas) DeleteColumn(x int) Canvas {
+	out := c.Resize(c.width - 1, c.height, 0, 0, Transparent)
+	for y, row := range out.cells {
+		copy(row[x:], c.cells[y][x+1:])
+		mendWide(row)
+	}
+	return out
+}
+
+//
+// Blank the halves of wide glyphs that a column was inserted between, or
+// whose other half was deleted.
+//
+func mendWide(row []Pixel) {
+	for x := range row {
+		if row[x] == Padding && CellOwner(row, x) == x {
+			row[x] = Pixel{R: ' '}
+		} else if IsWide(row[x].R) && (x + 1 >= len(row) || row[x+1] != Padding) {
+			row[x] = row[x].WithRune(' ')
+		}
+	}
+}
+
+//
+// Returns a copy of grid with its contents offset by (dx, dy).  Content
+// pushed past an edge either wraps around to the opposite edge or is
+// dropped, in which case the vacated cells become transparent.
+//
+func (grid Canvas) Shift(dx, dy int, wrap bool) Canvas {
+	width, height := grid.Bounds()
+	out := Filled(width, height, Transparent)
+	for y := range grid.cells {
+		for x := range grid.cells[y] {
+			nx, ny := x + dx, y + dy
+			if wrap {
+				nx = ((nx % width) + width) % width
+				ny = ((ny % height) + height) % height
+			}
+			if out.In(nx, ny) {
+				out.cells[ny][nx] = grid.cells[y][x]
+			}
+		}
+	}
+	return out
+}
+
+//
+// Returns a copy of canvas rolled by (dx, dy): what goes off one edge comes
+// back on the opposite one, however far it goes.  Wide glyphs that end up
+// split across the edges are blanked.
+//
+func (canvas Canvas) Scroll(dx, dy int) Canvas {
+	out := canvas.Shift(dx, dy, true)
+	for _, row := range out.cells {
+		mendWide(row)
+	}
+	return out
+}
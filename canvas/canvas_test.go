@@ -0,0 +1,277 @@
+package canvas
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+//
+// A canvas with a row for each of lines, with dots for transparency.  Wide
+// glyphs get their padding, so a line is as long as it looks.
+//
+func canvasOf(lines ...string) Canvas {
+	c := Filled(runewidth.StringWidth(lines[0]), len(lines), Transparent)
+	for y, line := range lines {
+		x := 0
+		for _, r := range line {
+			if r != '.' {
+				c.Set(x, y, Pixel{R: r})
+			}
+			x += runewidth.RuneWidth(r)
+		}
+	}
+	return c
+}
+
+func TestShift(t *testing.T) {
+	c := canvasOf(
+		"ab.",
+		"cd.",
+		"...",
+	)
+	tests := []struct {
+		name string
+		dx, dy int
+		wrap bool
+		want []string
+	}{
+		{"none", 0, 0, false, []string{"ab.", "cd.", "..."}},
+		{"right and down", 1, 1, false, []string{"...", ".ab", ".cd"}},
+		{"off the edge", -1, 0, false, []string{"b..", "d..", "..."}},
+		{"wrapped", -1, 0, true, []string{"b.a", "d.c", "..."}},
+		{"wrapped more than once", 5, -4, true, []string{"d.c", "...", "b.a"}},
+	}
+	for _, test := range tests {
+		got := c.Shift(test.dx, test.dy, test.wrap)
+		if !reflect.DeepEqual(rows(got), test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, rows(got), test.want)
+		}
+	}
+	if !reflect.DeepEqual(rows(c), []string{"ab.", "cd.", "..."}) {
+		t.Errorf("shifting changed the original: %q", rows(c))
+	}
+}
+
+func TestScroll(t *testing.T) {
+	c := canvasOf(
+		"abc",
+		"def",
+	)
+	tests := []struct {
+		name string
+		dx, dy int
+		want []string
+	}{
+		{"right", 1, 0, []string{"cab", "fde"}},
+		{"left", -1, 0, []string{"bca", "efd"}},
+		{"down", 0, 1, []string{"def", "abc"}},
+		{"up", 0, -1, []string{"def", "abc"}},
+		{"all the way round", 3, 2, []string{"abc", "def"}},
+		{"more than the size", 4, -5, []string{"fde", "cab"}},
+	}
+	for _, test := range tests {
+		if got := rows(c.Scroll(test.dx, test.dy)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+	if got, want := rows(canvasOf("世x").Scroll(2, 0)), []string{" x "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wide glyph split across the edge: got %q, want %q", got, want)
+	}
+}
+
+func TestOf(t *testing.T) {
+	ragged := [][]Pixel{
+		{{R: 'a'}, {R: 'b'}, {R: 'c'}, {R: 'd'}},
+		nil,
+		{{R: 'e'}},
+		{{R: 'f'}},
+	}
+	c := Of(ragged, 3, 3, Pixel{R: '-'})
+	if w, h := c.Bounds(); w != 3 || h != 3 {
+		t.Errorf("got %dx%d, want 3x3", w, h)
+	}
+	if got, want := rows(c), []string{"abc", "---", "e--"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for y, row := range c.Cells() {
+		if len(row) != 3 {
+			t.Errorf("row %d is %d long", y, len(row))
+		}
+	}
+
+	c = Of(nil, 2, 2, Transparent)
+	if got, want := rows(c), []string{"..", ".."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("from nothing: got %q, want %q", got, want)
+	}
+	c.Set(1, 1, Pixel{R: 'x'})
+	if c.At(1, 1) != (Pixel{R: 'x'}) {
+		t.Errorf("can't paint the last cell")
+	}
+}
+
+//
+// Off the canvas, reading gives transparency and writing does nothing.
+//
+func TestAtAndSetOff(t *testing.T) {
+	c := canvasOf("ab", "cd")
+	for _, p := range [][2]int{{-1, 0}, {0, -1}, {2, 0}, {0, 2}} {
+		if got := c.At(p[0], p[1]); got != Transparent {
+			t.Errorf("At(%d, %d) = %v, want transparent", p[0], p[1], got)
+		}
+		c.Set(p[0], p[1], Pixel{R: 'x'})
+	}
+	if got := rows(c); !reflect.DeepEqual(got, []string{"ab", "cd"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+//
+// Wide glyphs and their padding are set and overwritten together.
+//
+func TestSetWide(t *testing.T) {
+	tests := []struct {
+		name string
+		start string
+		x int
+		r rune
+		want string
+	}{
+		{"wide", "abcd", 1, '世', "a世d"},
+		{"over the glyph", "世cd", 0, 'x', "x cd"},
+		{"over the padding", "世cd", 1, 'x', " xcd"},
+		{"over a wide glyph's padding", "a世d", 0, '世', "世 d"},
+		{"overflowing", "abc", 2, '世', "abc"},
+	}
+	for _, test := range tests {
+		c := canvasOf(test.start)
+		c.Set(test.x, 0, Pixel{R: test.r})
+		if got := rows(c)[0]; got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		if len(c.Cells()[0]) != c.Width() {
+			t.Errorf("%s: row is %d long", test.name, len(c.Cells()[0]))
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := canvasOf(
+		"ab",
+		"cd",
+	)
+	tests := []struct {
+		name string
+		width, height, dx, dy int
+		want []string
+	}{
+		{"grown", 3, 3, 0, 0, []string{"ab-", "cd-", "---"}},
+		{"grown and moved", 4, 3, 1, 1, []string{"----", "-ab-", "-cd-"}},
+		{"shrunk", 1, 2, 0, 0, []string{"a", "c"}},
+		{"shrunk from the top left", 1, 1, -1, -1, []string{"d"}},
+	}
+	for _, test := range tests {
+		got := c.Resize(test.width, test.height, test.dx, test.dy, Pixel{R: '-'})
+		if w, h := got.Bounds(); w != test.width || h != test.height {
+			t.Errorf("%s: got %dx%d, want %dx%d", test.name, w, h, test.width, test.height)
+		}
+		if !reflect.DeepEqual(rows(got), test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, rows(got), test.want)
+		}
+	}
+	if !reflect.DeepEqual(rows(c), []string{"ab", "cd"}) {
+		t.Errorf("resizing changed the original: %q", rows(c))
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	src := [][]Pixel{{{R: 'a'}, {R: 'b'}}, {{R: 'c'}}}
+	clone := CloneCells(src)
+	if !reflect.DeepEqual(clone, src) {
+		t.Fatalf("got %v, want %v", clone, src)
+	}
+	clone[0][0] = Pixel{R: 'x'}
+	clone[1] = append(clone[1], Pixel{R: 'y'})
+	if want := [][]Pixel{{{R: 'a'}, {R: 'b'}}, {{R: 'c'}}}; !reflect.DeepEqual(src, want) {
+		t.Errorf("changing the clone changed the original to %v", src)
+	}
+
+	c := canvasOf("ab", "cd")
+	copied := c.Clone()
+	copied.Set(1, 1, Pixel{R: 'z'})
+	if got := rows(c); !reflect.DeepEqual(got, []string{"ab", "cd"}) {
+		t.Errorf("changing the cloned canvas changed the original to %q", got)
+	}
+	if w, h := copied.Bounds(); w != 2 || h != 2 {
+		t.Errorf("got a %dx%d clone, want 2x2", w, h)
+	}
+
+	layers := []Layer{{canvasOf("ab"), true}, {canvasOf("c."), false}}
+	clones := CloneLayers(layers)
+	if !reflect.DeepEqual(clones, layers) {
+		t.Fatalf("cloned layers differ")
+	}
+	clones[0].Grid.Set(0, 0, Pixel{R: 'x'})
+	clones[1].Visible = true
+	if rows(layers[0].Grid)[0] != "ab" || layers[1].Visible {
+		t.Errorf("changing the cloned layers changed the originals")
+	}
+}
+
+func TestInsertDeleteRow(t *testing.T) {
+	c := canvasOf("ab", "cd")
+	fill := Pixel{R: '-'}
+	tests := []struct {
+		name string
+		got Canvas
+		want []string
+	}{
+		{"insert at the top", c.InsertRow(0, fill), []string{"--", "ab", "cd"}},
+		{"insert in the middle", c.InsertRow(1, fill), []string{"ab", "--", "cd"}},
+		{"insert at the bottom", c.InsertRow(2, fill), []string{"ab", "cd", "--"}},
+		{"delete the top", c.DeleteRow(0), []string{"cd"}},
+		{"delete the bottom", c.DeleteRow(1), []string{"ab"}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(rows(test.got), test.want) || test.got.Height() != len(test.want) {
+			t.Errorf("%s: got %q, %d high, want %q", test.name, rows(test.got), test.got.Height(), test.want)
+		}
+	}
+	if !reflect.DeepEqual(rows(c), []string{"ab", "cd"}) {
+		t.Errorf("changed the original to %q", rows(c))
+	}
+}
+
+func TestInsertDeleteColumn(t *testing.T) {
+	c := canvasOf("ab", "cd")
+	fill := Pixel{R: '-'}
+	tests := []struct {
+		name string
+		got Canvas
+		want []string
+	}{
+		{"insert on the left", c.InsertColumn(0, fill), []string{"-ab", "-cd"}},
+		{"insert in the middle", c.InsertColumn(1, fill), []string{"a-b", "c-d"}},
+		{"insert on the right", c.InsertColumn(2, fill), []string{"ab-", "cd-"}},
+		{"delete the left", c.DeleteColumn(0), []string{"b", "d"}},
+		{"delete the right", c.DeleteColumn(1), []string{"a", "c"}},
+		{"insert through a wide glyph", canvasOf("世x").InsertColumn(1, fill), []string{" - x"}},
+		{"insert before a wide glyph", canvasOf("世x").InsertColumn(0, fill), []string{"-世x"}},
+		{"delete half of a wide glyph", canvasOf("世x").DeleteColumn(1), []string{" x"}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(rows(test.got), test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, rows(test.got), test.want)
+		}
+		for y, row := range test.got.Cells() {
+			if len(row) != test.got.Width() {
+				t.Errorf("%s: row %d is %d long, want %d", test.name, y, len(row), test.got.Width())
+			}
+		}
+	}
+	if !reflect.DeepEqual(rows(c), []string{"ab", "cd"}) {
+		t.Errorf("changed the original to %q", rows(c))
+	}
+}
+
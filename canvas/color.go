@@ -0,0 +1,240 @@
+package canvas
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// A foreground or background color, stored in a normalized form so that
+// pixels remain comparable: the empty string is the terminal default, a decimal number is
+// an index into the 256-color palette, and #rrggbb is a 24-bit color.
+//
+type Color string
+
+const NoColor Color = ""
+
+var colorNames = map[string]int{
+	"black": 0,
+	"red": 1,
+	"green": 2,
+	"yellow": 3,
+	"blue": 4,
+	"magenta": 5,
+	"cyan": 6,
+	"white": 7,
+	"brightblack": 8,
+	"gray": 8,
+	"grey": 8,
+	"brightred": 9,
+	"brightgreen": 10,
+	"brightyellow": 11,
+	"brightblue": 12,
+	"brightmagenta": 13,
+	"brightcyan": 14,
+	"brightwhite": 15,
+}
+
+func ParseColor(s string) (Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "none" || s == "default" {
+		return NoColor, nil
+	}
+	if index, ok := colorNames[s]; ok {
+		return Color(strconv.Itoa(index)), nil
+	}
+	if strings.HasPrefix(s, "#") {
+		if len(s) != 7 {
+			return NoColor, fmt.Errorf("bad color %q: expected #rrggbb", s)
+		}
+		if _, err := strconv.ParseUint(s[1:], 16, 32); err != nil {
+			return NoColor, fmt.Errorf("bad color %q: %w", s, err)
+		}
+		return Color(s), nil
+	}
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 || index > 255 {
+		return NoColor, fmt.Errorf("bad color %q: expected a name, #rrggbb or 0-255", s)
+	}
+	return Color(strconv.Itoa(index)), nil
+}
+
+//
+// The SGR parameters that select this color as the foreground.
+//
+func (c Color) SGR() string {
+	if c == NoColor {
+		return "39"
+	}
+	if strings.HasPrefix(string(c), "#") {
+		rgb, _ := strconv.ParseUint(string(c[1:]), 16, 32)
+		return fmt.Sprintf("38;2;%d;%d;%d", rgb >> 16, (rgb >> 8) & 0xff, rgb & 0xff)
+	}
+	return "38;5;" + string(c)
+}
+
+//
+// The SGR parameters that select this color as the background.
+//
+func (c Color) BgSGR() string {
+	if c == NoColor {
+		return "49"
+	}
+	return "4" + c.SGR()[1:]
+}
+
+//
+// Text attributes, which combine.  Terminals ignore the ones they don't
+// support, so a cell can always carry all of them.
+//
+type Attrs uint8
+
+const (
+	Bold Attrs = 1 << iota
+	Underline
+	Blink
+	Reverse
+)
+
+//
+// The SGR parameters that turn each attribute on and off again.  Bold is
+// turned off by "normal intensity", which isn't 21: that's double underline
+// in some terminals.
+//
+var attrSGR = []struct {
+	attr Attrs
+	on, off int
+}{
+	{Bold, 1, 22},
+	{Underline, 4, 24},
+	{Blink, 5, 25},
+	{Reverse, 7, 27},
+}
+
+//
+// The SGR parameters that take the terminal from the look of one pixel to
+// that of the next.
+//
+func StyleSGR(from, to Pixel) string {
+	var params []string
+	if to.FG != from.FG {
+		params = append(params, to.FG.SGR())
+	}
+	if to.BG != from.BG {
+		params = append(params, to.BG.BgSGR())
+	}
+	for _, a := range attrSGR {
+		on, was := to.Attrs & a.attr != 0, from.Attrs & a.attr != 0
+		if on && !was {
+			params = append(params, strconv.Itoa(a.on))
+		} else if was && !on {
+			params = append(params, strconv.Itoa(a.off))
+		}
+	}
+	return strings.Join(params, ";")
+}
+
+//
+// Interpret the parameters of an SGR escape sequence (the part between
+// "\x1b[" and "m"), returning current with the colors and attributes in
+// effect afterwards.
+//
+func ParseSGR(params string, current Pixel) Pixel {
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		n, err := strconv.Atoi(fields[i])
+		if fields[i] == "" {
+			n, err = 0, nil
+		}
+		if err != nil {
+			continue
+		}
+		//
+		// Background colors are the foreground ones plus 10.
+		//
+		target := &current.FG
+		if (n >= 40 && n <= 49) || (n >= 100 && n <= 107) {
+			target, n = &current.BG, n - 10
+		}
+		for _, a := range attrSGR {
+			if n == a.on {
+				current.Attrs |= a.attr
+			} else if n == a.off {
+				current.Attrs &^= a.attr
+			}
+		}
+		switch {
+		case n == 0:
+			current.FG, current.BG, current.Attrs = NoColor, NoColor, 0
+		case n == 39:
+			*target = NoColor
+		case n >= 30 && n <= 37:
+			*target = Color(strconv.Itoa(n - 30))
+		case n >= 90 && n <= 97:
+			*target = Color(strconv.Itoa(n - 90 + 8))
+		case n == 38 && i + 2 < len(fields) && fields[i+1] == "5":
+			if c, err := sgrColor(fields[i+2:i+3], "%d"); err == nil {
+				*target = c
+			}
+			i += 2
+		case n == 38 && i + 4 < len(fields) && fields[i+1] == "2":
+			if c, err := sgrColor(fields[i+2:i+5], "#%02x%02x%02x"); err == nil {
+				*target = c
+			}
+			i += 4
+		}
+	}
+	return current
+}
+
+//
+// The color that the numbers in fields select when put into format, which
+// ParseColor has the last word on, so that nothing out of range gets in.
+//
+func sgrColor(fields []string, format string) (Color, error) {
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return NoColor, err
+		}
+		values[i] = n
+	}
+	return ParseColor(fmt.Sprintf(format, values...))
+}
+
+var ansiColors = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+//
+// The color as #rrggbb, for formats that don't know about terminal palettes,
+// or the empty string for the default color.  Indexed colors follow xterm:
+// 16 basic colors, a 6x6x6 cube and a 24-step grayscale ramp.
+//
+func (c Color) Hex() string {
+	if c == NoColor || strings.HasPrefix(string(c), "#") {
+		return string(c)
+	}
+	index, err := strconv.Atoi(string(c))
+	if err != nil || index < 0 || index > 255 {
+		return ""
+	}
+	var rgb [3]uint8
+	switch {
+	case index < 16:
+		rgb = ansiColors[index]
+	case index < 232:
+		levels := [6]uint8{0, 95, 135, 175, 215, 255}
+		index -= 16
+		rgb = [3]uint8{levels[index / 36], levels[(index / 6) % 6], levels[index % 6]}
+	default:
+		gray := uint8(8 + 10 * (index - 232))
+		rgb = [3]uint8{gray, gray, gray}
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
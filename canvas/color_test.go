@@ -0,0 +1,29 @@
+package canvas
+
+import "testing"
+
+func TestParseSGR(t *testing.T) {
+	start := Pixel{FG: "1", BG: "4", Attrs: Bold}
+	tests := []struct {
+		params string
+		want Pixel
+	}{
+		{"", Pixel{}},
+		{"32;1", Pixel{FG: "2", BG: "4", Attrs: Bold}},
+		{"22;49", Pixel{FG: "1"}},
+		{"38;5;200", Pixel{FG: "200", BG: "4", Attrs: Bold}},
+		{"48;5;007", Pixel{FG: "1", BG: "7", Attrs: Bold}},
+		{"38;2;255;128;0", Pixel{FG: "#ff8000", BG: "4", Attrs: Bold}},
+		{"38;5;256", start},
+		{"48;5;x", start},
+		{"38;5;;4", Pixel{FG: "1", BG: "4", Attrs: Bold | Underline}},
+		{"38;2;300;0;0", start},
+		{"48;2;-1;255;255", start},
+		{"38;2;1;2;z", start},
+	}
+	for _, test := range tests {
+		if got := ParseSGR(test.params, start); got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.params, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package canvas
+
+//
+// Replace the contiguous region of target pixels that contains (x, y).
+// When diagonal is set, cells touching only at a corner are contiguous too.
+// Uses an explicit stack, since a recursive fill of a large empty canvas
+// gets deep quickly.
+//
+func (canvas Canvas) FloodFill(x, y int, target, replacement Pixel, diagonal bool) {
+	if target == replacement {
+		return
+	}
+	inside := func(x, y int) bool {
+		return canvas.In(x, y) && canvas.At(x, y) == target
+	}
+
+	neighbors := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if diagonal {
+		neighbors = append(neighbors, [2]int{1, 1}, [2]int{1, -1}, [2]int{-1, 1}, [2]int{-1, -1})
+	}
+
+	stack := [][2]int{{x, y}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !inside(p[0], p[1]) {
+			continue
+		}
+		canvas.Set(p[0], p[1], replacement)
+		for _, n := range neighbors {
+			if inside(p[0] + n[0], p[1] + n[1]) {
+				stack = append(stack, [2]int{p[0] + n[0], p[1] + n[1]})
+			}
+		}
+	}
+}
+
+//
+// Draw a straight line from (x0, y0) to (x1, y1) inclusive using
+// Bresenham's algorithm.  Points outside the canvas are skipped.
+//
+func (canvas Canvas) Line(x0, y0, x1, y1 int, p Pixel) {
+	LinePoints(x0, y0, x1, y1, func(x, y, dx, dy int) {
+		canvas.Set(x, y, p)
+	})
+}
+
+//
+// Call f for each point of the line from (x0, y0) to (x1, y1), in order,
+// with the step from it to the next point.  The last point gets the step
+// that led to it, and a line that's a single point gets no step at all.
+//
+func LinePoints(x0, y0, x1, y1 int, f func(x, y, dx, dy int)) {
+	dx, sx := x1 - x0, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	dy, sy := y1 - y0, 1
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	err := dx - dy
+	stepX, stepY := 0, 0
+	for {
+		if x0 == x1 && y0 == y1 {
+			f(x0, y0, stepX, stepY)
+			return
+		}
+		stepX, stepY = 0, 0
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			stepX = sx
+		}
+		if e2 < dx {
+			err += dx
+			stepY = sy
+		}
+		f(x0, y0, stepX, stepY)
+		x0, y0 = x0 + stepX, y0 + stepY
+	}
+}
+
+//
+// Draw the rectangle with opposite corners (x0, y0) and (x1, y1), in
+// either order, clipped to the canvas.
+//
+func (canvas Canvas) Rect(x0, y0, x1, y1 int, p Pixel, fill bool) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if fill || y == y0 || y == y1 || x == x0 || x == x1 {
+				canvas.Set(x, y, p)
+			}
+		}
+	}
+}
+
+//
+// Calls plot for the points of the first quadrant of an ellipse centered
+// on the origin, using the midpoint ellipse algorithm.  The decision
+// variables are scaled by 4 to keep everything in integers.
+//
+func ellipseQuadrant(rx, ry int, plot func(x, y int)) {
+	if rx < 0 {
+		rx = -rx
+	}
+	if ry < 0 {
+		ry = -ry
+	}
+	if ry == 0 {
+		for x := 0; x <= rx; x++ {
+			plot(x, 0)
+		}
+		return
+	}
+
+	rx2, ry2 := int64(rx) * int64(rx), int64(ry) * int64(ry)
+	x, y := int64(0), int64(ry)
+	px, py := int64(0), 2 * rx2 * y
+
+	p := 4 * ry2 - 4 * rx2 * y + rx2
+	for px < py {
+		plot(int(x), int(y))
+		x++
+		px += 2 * ry2
+		if p < 0 {
+			p += 4 * (ry2 + px)
+		} else {
+			y--
+			py -= 2 * rx2
+			p += 4 * (ry2 + px - py)
+		}
+	}
+
+	p = ry2 * (2 * x + 1) * (2 * x + 1) + 4 * rx2 * (y - 1) * (y - 1) - 4 * rx2 * ry2
+	for y >= 0 {
+		plot(int(x), int(y))
+		y--
+		py -= 2 * rx2
+		if p > 0 {
+			p += 4 * (rx2 - py)
+		} else {
+			x++
+			px += 2 * ry2
+			p += 4 * (rx2 - py + px)
+		}
+	}
+}
+
+//
+// Draw the outline of an ellipse centered on (cx, cy), clipped to the canvas.
+//
+func (canvas Canvas) Ellipse(cx, cy, rx, ry int, p Pixel) {
+	ellipseQuadrant(rx, ry, func(x, y int) {
+		canvas.Set(cx + x, cy + y, p)
+		canvas.Set(cx - x, cy + y, p)
+		canvas.Set(cx + x, cy - y, p)
+		canvas.Set(cx - x, cy - y, p)
+	})
+}
+
+func (canvas Canvas) FillEllipse(cx, cy, rx, ry int, p Pixel) {
+	ellipseQuadrant(rx, ry, func(x, y int) {
+		for sx := -x; sx <= x; sx++ {
+			canvas.Set(cx + sx, cy + y, p)
+			canvas.Set(cx + sx, cy - y, p)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package canvas
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//
+// The glyphs of c a row at a time, with transparent cells as dots and
+// padding left out, so that expected canvases can be written as strings.
+//
+func rows(c Canvas) []string {
+	var out []string
+	for _, row := range c.Cells() {
+		var b strings.Builder
+		for _, p := range row {
+			switch p {
+			case Transparent:
+				b.WriteRune('.')
+			case Padding:
+			default:
+				b.WriteRune(p.R)
+			}
+		}
+		out = append(out, b.String())
+	}
+	return out
+}
+
+func TestLinePoints(t *testing.T) {
+	tests := []struct {
+		name string
+		x0, y0, x1, y1 int
+		want [][4]int
+	}{
+		{"point", 2, 2, 2, 2, [][4]int{{2, 2, 0, 0}}},
+		{"right", 0, 0, 3, 0, [][4]int{{0, 0, 1, 0}, {1, 0, 1, 0}, {2, 0, 1, 0}, {3, 0, 1, 0}}},
+		{"up", 1, 2, 1, 0, [][4]int{{1, 2, 0, -1}, {1, 1, 0, -1}, {1, 0, 0, -1}}},
+		{"diagonal", 0, 0, 2, 2, [][4]int{{0, 0, 1, 1}, {1, 1, 1, 1}, {2, 2, 1, 1}}},
+		{"up left", 2, 2, 0, 0, [][4]int{{2, 2, -1, -1}, {1, 1, -1, -1}, {0, 0, -1, -1}}},
+		{"shallow", 0, 0, 4, 1, [][4]int{{0, 0, 1, 0}, {1, 0, 1, 0}, {2, 0, 1, 1}, {3, 1, 1, 0}, {4, 1, 1, 0}}},
+	}
+	for _, test := range tests {
+		var got [][4]int
+		LinePoints(test.x0, test.y0, test.x1, test.y1, func(x, y, dx, dy int) {
+			got = append(got, [4]int{x, y, dx, dy})
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		name string
+		x0, y0, x1, y1 int
+		want []string
+	}{
+		{"point", 2, 2, 2, 2, []string{"    ", "    ", "  # ", "    "}},
+		{"horizontal", 0, 1, 3, 1, []string{"    ", "####", "    ", "    "}},
+		{"vertical", 2, 3, 2, 0, []string{"  # ", "  # ", "  # ", "  # "}},
+		{"diagonal", 0, 0, 3, 3, []string{"#   ", " #  ", "  # ", "   #"}},
+		{"negative slope", 0, 3, 3, 0, []string{"   #", "  # ", " #  ", "#   "}},
+		{"steep", 0, 0, 1, 3, []string{"#   ", "#   ", " #  ", " #  "}},
+		{"either way", 1, 3, 0, 0, []string{"#   ", "#   ", " #  ", " #  "}},
+		{"clipped", -2, 1, 5, 1, []string{"    ", "####", "    ", "    "}},
+		{"off the canvas", -3, -1, -1, -3, []string{"    ", "    ", "    ", "    "}},
+	}
+	for _, test := range tests {
+		c := New(4, 4)
+		c.Line(test.x0, test.y0, test.x1, test.y1, Pixel{R: '#'})
+		if got := rows(c); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEllipseQuadrant(t *testing.T) {
+	tests := []struct {
+		rx, ry int
+		want [][2]int
+	}{
+		{0, 0, [][2]int{{0, 0}}},
+		{3, 0, [][2]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}}},
+		{0, 2, [][2]int{{0, 2}, {0, 1}, {0, 0}}},
+		{2, 1, [][2]int{{0, 1}, {1, 1}, {2, 0}}},
+		{-2, -1, [][2]int{{0, 1}, {1, 1}, {2, 0}}},
+		{3, 3, [][2]int{{0, 3}, {1, 3}, {2, 2}, {3, 1}, {3, 0}}},
+	}
+	for _, test := range tests {
+		var got [][2]int
+		ellipseQuadrant(test.rx, test.ry, func(x, y int) {
+			got = append(got, [2]int{x, y})
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%dx%d: got %v, want %v", test.rx, test.ry, got, test.want)
+		}
+	}
+}
+
+func TestEllipse(t *testing.T) {
+	outline := []string{
+		"  ooo  ",
+		" o   o ",
+		"o     o",
+		" o   o ",
+		"  ooo  ",
+	}
+	filled := []string{
+		"  ooo  ",
+		" ooooo ",
+		"ooooooo",
+		" ooooo ",
+		"  ooo  ",
+	}
+	c := New(7, 5)
+	c.Ellipse(3, 2, 3, 2, Pixel{R: 'o'})
+	if got := rows(c); !reflect.DeepEqual(got, outline) {
+		t.Errorf("outline: got %q, want %q", got, outline)
+	}
+	c = New(7, 5)
+	c.FillEllipse(3, 2, 3, 2, Pixel{R: 'o'})
+	if got := rows(c); !reflect.DeepEqual(got, filled) {
+		t.Errorf("filled: got %q, want %q", got, filled)
+	}
+}
+
+func TestRect(t *testing.T) {
+	tests := []struct {
+		name string
+		x0, y0, x1, y1 int
+		fill bool
+		want []string
+	}{
+		{"outline", 0, 0, 3, 2, false, []string{"####", "#  #", "####"}},
+		{"filled", 0, 0, 3, 2, true, []string{"####", "####", "####"}},
+		{"corners either way", 2, 2, 1, 0, true, []string{" ## ", " ## ", " ## "}},
+		{"clipped", 2, 1, 6, 6, false, []string{"    ", "  ##", "  # "}},
+		{"a cell", 1, 1, 1, 1, false, []string{"    ", " #  ", "    "}},
+	}
+	for _, test := range tests {
+		c := New(4, 3)
+		c.Rect(test.x0, test.y0, test.x1, test.y1, Pixel{R: '#'}, test.fill)
+		if got := rows(c); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
@@ -0,0 +1,385 @@
+package canvas
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//
+// Whether line looks like the first line of a file saved by gopnik.
+//
+func IsHeader(line string) bool {
+	if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "gopnik" {
+		return true
+	}
+	_, err := parseLegacyHeader(line)
+	return err == nil
+}
+
+//
+// The first line of files in the current format.  Older files start with
+// just the dimensions, and optionally the number of layers.  Either way, the
+// header is followed by height rows per layer, bottom layer first, with
+// transparent cells written as NUL.
+//
+const formatMagic = "gopnik v2"
+
+//
+// The metadata in a file's header, which tells Load how to read the
+// rest of the file.
+//
+type Header struct {
+	Width, Height int
+	Layers int
+	Hidden map[int]bool
+	Marks map[string]Point
+	lines int
+}
+
+//
+// Limits on what a file may declare, so that a corrupt header can't make
+// Load allocate an absurd amount of memory.
+//
+const (
+	MaxWidth = 4096
+	MaxHeight = 4096
+	MaxLayers = 64
+)
+
+func (h Header) Validate() error {
+	if h.Width < 1 || h.Width > MaxWidth || h.Height < 1 || h.Height > MaxHeight {
+		return fmt.Errorf("bad dimensions %dx%d: expected at most %dx%d", h.Width, h.Height, MaxWidth, MaxHeight)
+	}
+	if h.Layers < 1 || h.Layers > MaxLayers {
+		return fmt.Errorf("bad layer count %d: expected 1-%d", h.Layers, MaxLayers)
+	}
+	return nil
+}
+
+//
+// Read a file saved by gopnik, returning its header along with its layers.
+//
+func Load(fin io.Reader) (h Header, layers []Layer, err error) {
+	reader := bufio.NewReader(fin)
+	firstLine, err := reader.ReadString('\n')
+	if err == io.EOF && firstLine == "" {
+		return h, nil, fmt.Errorf("empty file")
+	} else if err != nil && err != io.EOF {
+		return h, nil, err
+	}
+	if fields := strings.Fields(firstLine); len(fields) > 0 && fields[0] == "gopnik" {
+		if strings.TrimSpace(firstLine) != formatMagic {
+			return h, nil, fmt.Errorf("line 1: unsupported format %q", strings.TrimSpace(firstLine))
+		}
+		h, err = readHeader(reader)
+	} else {
+		h, err = parseLegacyHeader(firstLine)
+	}
+	if err != nil {
+		return h, nil, err
+	}
+	if err := h.Validate(); err != nil {
+		return h, nil, fmt.Errorf("line %d: %w", h.lines, err)
+	}
+
+	for i := 0; i < h.Layers; i++ {
+		grid, err := readGrid(reader, h.Width, h.Height, h.lines + 1 + i * h.Height)
+		if err != nil {
+			return h, nil, err
+		}
+		layers = append(layers, Layer{grid, !h.Hidden[i]})
+	}
+
+	return h, layers, nil
+}
+
+//
+// The v1 header is a single line: width, height and, if there's more than
+// one, the number of layers.
+//
+func parseLegacyHeader(line string) (h Header, err error) {
+	h.lines = 1
+	split := strings.Fields(line)
+	if len(split) < 2 || len(split) > 3 {
+		return h, fmt.Errorf("line 1: bad header %q", strings.TrimSpace(line))
+	}
+	if h.Width, err = strconv.Atoi(split[0]); err != nil {
+		return h, fmt.Errorf("line 1: bad width %q", split[0])
+	}
+	if h.Height, err = strconv.Atoi(split[1]); err != nil {
+		return h, fmt.Errorf("line 1: bad height %q", split[1])
+	}
+	h.Layers = 1
+	if len(split) > 2 {
+		if h.Layers, err = strconv.Atoi(split[2]); err != nil {
+			return h, fmt.Errorf("line 1: bad layer count %q", split[2])
+		}
+	}
+	return h, nil
+}
+
+//
+// The v2 header is a "key value" pair per line, terminated by an empty line.
+// Keys that this version doesn't know about are skipped, so that newer files
+// still load.
+//
+func readHeader(reader *bufio.Reader) (h Header, err error) {
+	h.Layers = 1
+	h.Hidden = map[int]bool{}
+	h.Marks = map[string]Point{}
+	h.lines = 1
+	for {
+		line, err := reader.ReadString('\n')
+		h.lines++
+		if err == io.EOF {
+			return h, fmt.Errorf("line %d: header isn't terminated by an empty line", h.lines)
+		} else if err != nil {
+			return h, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "width":
+			h.Width, err = strconv.Atoi(value)
+		case "height":
+			h.Height, err = strconv.Atoi(value)
+		case "layers":
+			h.Layers, err = strconv.Atoi(value)
+		case "hidden":
+			//
+			// Layers that aren't visible, counting from 1 at the bottom.
+			//
+			for _, field := range strings.Fields(value) {
+				var n int
+				if n, err = strconv.Atoi(field); err != nil {
+					break
+				}
+				h.Hidden[n-1] = true
+			}
+		case "mark":
+			//
+			// A name and a position, one mark per line.
+			//
+			var p Point
+			fields := strings.Fields(value)
+			if len(fields) != 3 {
+				err = errors.New("expected a name and a position")
+			} else if p.X, err = strconv.Atoi(fields[1]); err == nil {
+				p.Y, err = strconv.Atoi(fields[2])
+			}
+			if err == nil {
+				h.Marks[fields[0]] = p
+			}
+		}
+		if err != nil {
+			return h, fmt.Errorf("line %d: bad %s %q", h.lines, key, value)
+		}
+	}
+	return h, nil
+}
+
+//
+// Read height rows of width columns each.  line is the number of the
+// first row within the file, for error messages.
+//
+func readGrid(reader *bufio.Reader, width, height, line int) (Canvas, error) {
+	canvas := make([][]Pixel, height)
+
+	for y := 0; y < height; y, line = y + 1, line + 1 {
+		style := Pixel{}
+		for x := 0; x < width; {
+			r, _, err := reader.ReadRune()
+			if err == io.EOF && x == 0 {
+				return Canvas{}, fmt.Errorf("line %d: expected %d rows, got %d", line, height, y)
+			} else if err == io.EOF || r == '\n' {
+				return Canvas{}, fmt.Errorf("line %d: expected %d columns, got %d", line, width, x)
+			} else if err != nil {
+				return Canvas{}, err
+			}
+			//
+			// Colored cells are preceded by SGR escape sequences, the same
+			// ones that Dump uses to render them in the terminal.
+			//
+			if r == '\x1b' {
+				sequence, err := reader.ReadString('m')
+				if err != nil || strings.Contains(sequence, "\n") {
+					return Canvas{}, fmt.Errorf("line %d: unterminated escape sequence", line)
+				}
+				style = ParseSGR(strings.TrimPrefix(strings.TrimSuffix(sequence, "m"), "["), style)
+				continue
+			}
+			//
+			// Rows are measured in columns, so wide glyphs count twice.
+			//
+			if IsWide(r) && x + 1 < width {
+				canvas[y] = append(canvas[y], style.WithRune(r), Padding)
+				x += 2
+			} else if IsWide(r) {
+				canvas[y] = append(canvas[y], style.WithRune(' '))
+				x++
+			} else if isControl(r) {
+				canvas[y] = append(canvas[y], style.WithRune(' '))
+				x++
+			} else {
+				canvas[y] = append(canvas[y], style.WithRune(r))
+				x++
+			}
+		}
+		//
+		// Read EOL, which may be preceded by a color reset.  Anything else
+		// means the row is too long.  The last row may lack the newline.
+		//
+		rest, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return Canvas{}, err
+		}
+		if extra := StripEscapes(strings.TrimRight(rest, "\r\n")); extra != "" {
+			return Canvas{}, fmt.Errorf("line %d: expected %d columns, got %d", line, width, width + utf8.RuneCountInString(extra))
+		}
+	}
+
+	return Canvas{width, height, canvas}, nil
+}
+
+//
+// Remove the SGR escape sequences from s.
+//
+func StripEscapes(s string) string {
+	var out strings.Builder
+	for {
+		i := strings.Index(s, "\x1b[")
+		if i < 0 {
+			break
+		}
+		out.WriteString(s[:i])
+		j := strings.IndexByte(s[i:], 'm')
+		if j < 0 {
+			s = ""
+			break
+		}
+		s = s[i + j + 1:]
+	}
+	out.WriteString(s)
+	return out.String()
+}
+
+//
+// Write the header and all the layers in the format that Load reads.
+//
+// Rows are exactly width columns long, so trailing spaces matter, but some
+// editors and tools strip them.  With trimSafe, rows that end in a space
+// have a color reset written after them, which Load skips like any other
+// escape at the end of a row, so nothing is lost when the spaces before it
+// would otherwise have been.  Files saved that way still load in versions
+// that predate it.
+//
+func Save(layers []Layer, width, height int, marks map[string]Point, trimSafe bool, fout io.Writer) error {
+	if _, err := fmt.Fprintf(fout, "%s\nwidth %d\nheight %d\nlayers %d\n", formatMagic, width, height, len(layers)); err != nil {
+		return err
+	}
+	var hidden []string
+	for i, l := range layers {
+		if !l.Visible {
+			hidden = append(hidden, strconv.Itoa(i + 1))
+		}
+	}
+	if len(hidden) > 0 {
+		if _, err := fmt.Fprintf(fout, "hidden %s\n", strings.Join(hidden, " ")); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(marks)) {
+		if _, err := fmt.Fprintf(fout, "mark %s %d %d\n", name, marks[name].X, marks[name].Y); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(fout, "\n"); err != nil {
+		return err
+	}
+	for _, l := range layers {
+		if err := l.Grid.dump(fout, trimSafe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// Write the rows of the canvas a line each, the way that Save writes
+// every layer.
+//
+func (canvas Canvas) Dump(fout io.Writer) error {
+	return canvas.dump(fout, false)
+}
+
+func (canvas Canvas) dump(fout io.Writer, trimSafe bool) error {
+	for _, row := range canvas.cells {
+		line := FormatRow(row)
+		if trimSafe && strings.HasSuffix(line, " ") {
+			line += "\x1b[0m"
+		}
+		if _, err := io.WriteString(fout, line + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// Control characters other than NUL, which is how transparent cells are
+// written, can't be drawn.  They come out as spaces, and go in as them too.
+//
+func isControl(r rune) bool {
+	return r != 0 && unicode.IsControl(r)
+}
+
+//
+// The cells of a row as text.  The row leaves the terminal in the default
+// color, so that rows (or parts of them) can be joined.
+//
+func FormatRow(row []Pixel) string {
+	var b strings.Builder
+	//
+	// Emit escapes only where the color changes, so that uncolored
+	// cells don't carry any overhead.
+	//
+	style := Pixel{}
+	for x := range row {
+		if p := row[x].Style(); p != style {
+			b.WriteString("\x1b[" + StyleSGR(style, p) + "m")
+			style = p
+		}
+		//
+		// Padding is covered by the wide glyph to its left.  If the two
+		// got separated, e.g. by compositing layers, show blanks instead.
+		//
+		r := row[x].R
+		if r == PaddingRune && CellOwner(row, x) != x {
+			continue
+		} else if r == PaddingRune || (IsWide(r) && (x + 1 >= len(row) || row[x+1] != Padding)) {
+			r = ' '
+		} else if isControl(r) {
+			//
+			// A newline or an escape would garble the terminal, and the
+			// file couldn't be read back either.
+			//
+			r = ' '
+		}
+		b.WriteRune(r)
+	}
+	if style != (Pixel{}) {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
@@ -0,0 +1,315 @@
+package canvas
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		width, height int
+		layers [][]string
+		hidden map[int]bool
+		marks map[string]Point
+	}{
+		{
+			name: "v1",
+			file: "3 2\nabc\nd f\n",
+			width: 3, height: 2,
+			layers: [][]string{{"abc", "d f"}},
+		},
+		{
+			name: "v1 without the last newline",
+			file: "2 2\nab\ncd",
+			width: 2, height: 2,
+			layers: [][]string{{"ab", "cd"}},
+		},
+		{
+			name: "v1 with layers",
+			file: "2 1 2\nab\nc\x00\n",
+			width: 2, height: 1,
+			layers: [][]string{{"ab"}, {"c."}},
+		},
+		{
+			name: "v2",
+			file: "gopnik v2\nwidth 2\nheight 1\nlayers 2\nhidden 2\nmark a 1 0\n\nab\n\x00c\n",
+			width: 2, height: 1,
+			layers: [][]string{{"ab"}, {".c"}},
+			hidden: map[int]bool{1: true},
+			marks: map[string]Point{"a": {1, 0}},
+		},
+		{
+			name: "v2 from a newer version",
+			file: "gopnik v2\nwidth 1\nheight 1\ncreator someday\n\nx\n",
+			width: 1, height: 1,
+			layers: [][]string{{"x"}},
+		},
+	}
+	for _, test := range tests {
+		h, layers, err := Load(strings.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if h.Width != test.width || h.Height != test.height {
+			t.Errorf("%s: got %dx%d, want %dx%d", test.name, h.Width, h.Height, test.width, test.height)
+		}
+		var got [][]string
+		for i, l := range layers {
+			got = append(got, rows(l.Grid))
+			if l.Visible == test.hidden[i] {
+				t.Errorf("%s: layer %d visible is %v", test.name, i + 1, l.Visible)
+			}
+		}
+		if !reflect.DeepEqual(got, test.layers) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.layers)
+		}
+		if len(h.Marks) + len(test.marks) > 0 && !reflect.DeepEqual(h.Marks, test.marks) {
+			t.Errorf("%s: got marks %v, want %v", test.name, h.Marks, test.marks)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"", "empty file"},
+		{"gopnik v3\n", "line 1: unsupported format \"gopnik v3\""},
+		{"gopnik v2\nwidth 1\nheight 1\n", "line 4: header isn't terminated by an empty line"},
+		{"gopnik v2\nwidth 1\nheight 1\nmark a 1\n\nx\n", "line 4: bad mark \"a 1\""},
+		{"gopnik v2\nwidth one\n\n", "line 2: bad width \"one\""},
+		{"2\nab\n", "line 1: bad header \"2\""},
+		{"0 1\n\n", "line 1: bad dimensions 0x1: expected at most 4096x4096"},
+		{"2 2\nab\n", "line 3: expected 2 rows, got 1"},
+		{"2 1\na\n", "line 2: expected 2 columns, got 1"},
+		{"2 1\nabc\n", "line 2: expected 2 columns, got 3"},
+	}
+	for _, test := range tests {
+		_, _, err := Load(strings.NewReader(test.file))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: got %v, want %s", test.file, err, test.want)
+		}
+	}
+}
+
+//
+// What's saved loads back the same, whichever version it was loaded from.
+//
+func TestSaveRoundTrip(t *testing.T) {
+	for _, file := range []string{
+		"3 2 2\na世\nb c\n\x00\x00x\n\x00\x00\x00\n",
+		"gopnik v2\nwidth 2\nheight 1\nlayers 2\nhidden 1\nmark b 0 0\nmark a 1 0\n\n\x1b[38;5;1mab\x1b[0m\n\x00c\n",
+	} {
+		h, layers, err := Load(strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("%q: %v", file, err)
+		}
+		var b strings.Builder
+		if err := Save(layers, h.Width, h.Height, h.Marks, false, &b); err != nil {
+			t.Fatal(err)
+		}
+		h2, layers2, err := Load(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatalf("%q: %v", b.String(), err)
+		}
+		if h2.Width != h.Width || h2.Height != h.Height || !reflect.DeepEqual(layers2, layers) || !maps.Equal(h2.Marks, h.Marks) {
+			t.Errorf("%q saved as %q, which loads differently", file, b.String())
+		}
+	}
+}
+
+func TestFormatRow(t *testing.T) {
+	red := Pixel{R: 'a', FG: "1"}
+	tests := []struct {
+		name string
+		row []Pixel
+		want string
+	}{
+		{"plain", []Pixel{{R: 'a'}, {R: 'b'}}, "ab"},
+		{"a run of color", []Pixel{red, {R: 'b', FG: "1"}, {R: 'c'}}, "\x1b[38;5;1mab\x1b[39mc"},
+		{"color to the end", []Pixel{red, red}, "\x1b[38;5;1maa\x1b[0m"},
+		{"only what changes", []Pixel{{R: 'a', FG: "1", BG: "4"}, {R: 'b', FG: "1"}}, "\x1b[38;5;1;48;5;4ma\x1b[49mb\x1b[0m"},
+		{"wide", []Pixel{{R: '世'}, Padding, {R: 'x'}}, "世x"},
+		{"wide without padding", []Pixel{{R: '世'}, {R: 'x'}}, " x"},
+		{"padding without a glyph", []Pixel{{R: 'x'}, Padding}, "x "},
+		{"control characters", []Pixel{{R: '\n'}, {R: '\x1b'}}, "  "},
+	}
+	for _, test := range tests {
+		if got := FormatRow(test.row); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+//
+// Percent signs once went through a format string on their way out.
+//
+func TestPercentSigns(t *testing.T) {
+	c := canvasOf("%s%d%%", "100%  ")
+	c.Set(4, 1, Pixel{R: '%', FG: "2"})
+	if got, want := FormatRow(c.Cells()[0]), "%s%d%%"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := FormatRow(c.Cells()[1]), "100%\x1b[38;5;2m%\x1b[39m "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var b strings.Builder
+	if err := c.Dump(&b); err != nil {
+		t.Fatal(err)
+	}
+	if StripEscapes(b.String()) != "%s%d%%\n100%% \n" {
+		t.Errorf("dumped %q", b.String())
+	}
+	b.Reset()
+	if err := Save([]Layer{{c, true}}, 6, 2, nil, false, &b); err != nil {
+		t.Fatal(err)
+	}
+	_, layers, err := Load(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(layers[0].Grid, c) {
+		t.Errorf("got %q back, want %q", rows(layers[0].Grid), rows(c))
+	}
+}
+
+
+//
+// A canvas width cells wide holding text, a cell per rune (wide ones take
+// two), styled by the bytes of style in turn.  NUL is a transparent cell.
+//
+func fuzzCanvas(text string, style []byte, width int) Canvas {
+	colors := []Color{NoColor, "1", "9", "196", "#ff8000", "0"}
+	//
+	// Enough rows for every rune to be wide, but not so many that the
+	// canvas is too high to load.
+	//
+	runes := []rune(text)
+	runes = runes[:min(len(runes), 256)]
+	height := max(1, (2 * len(runes) + width - 1) / width)
+	c := New(width, height)
+	x, y := 0, 0
+	for i, r := range runes {
+		p := Pixel{R: r}
+		if len(style) > 0 {
+			s := style[i % len(style)]
+			p.FG, p.BG, p.Attrs = colors[int(s) % len(colors)], colors[int(s >> 3) % len(colors)], Attrs(s >> 4)
+		}
+		if r == 0 {
+			p = Transparent
+		}
+		if x >= width || (IsWide(r) && x + 1 >= width) {
+			x, y = 0, y + 1
+		}
+		c.Set(x, y, p)
+		x++
+		if IsWide(r) {
+			x++
+		}
+	}
+	return c
+}
+
+//
+// Whatever's on a canvas loads back as it was saved, except for control
+// characters, which are written as spaces.
+//
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("%", []byte{}, uint8(1))
+	f.Add("%s%d%%!v", []byte{1, 2}, uint8(4))
+	f.Add("漢字😀a漢", []byte{}, uint8(5))
+	f.Add("a\x00\x00b\x00", []byte{0, 9}, uint8(3))
+	f.Add("ab  cd    ", []byte{0}, uint8(5))
+	f.Add("  x  ", []byte{0x00, 0x09, 0x12, 0x1b, 0x24, 0xf5}, uint8(5))
+	f.Add("\t\x1b[31m\n\r\u0085", []byte{3}, uint8(4))
+	f.Fuzz(func(t *testing.T, text string, style []byte, width uint8) {
+		c := fuzzCanvas(text, style, int(width) % 16 + 1)
+		want := c.Clone()
+		for _, row := range want.Cells() {
+			for x := range row {
+				if isControl(row[x].R) {
+					row[x].R = ' '
+				}
+			}
+		}
+		w, h := c.Bounds()
+		marks := map[string]Point{"a": {0, h - 1}}
+		for _, trimSafe := range []bool{false, true} {
+			var b strings.Builder
+			if err := Save([]Layer{{c, true}, NewLayer(w, h)}, w, h, marks, trimSafe, &b); err != nil {
+				t.Fatal(err)
+			}
+			header, layers, err := Load(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatalf("trimSafe %v: %v\n%q", trimSafe, err, b.String())
+			}
+			if header.Width != w || header.Height != h || !reflect.DeepEqual(header.Marks, marks) || len(layers) != 2 {
+				t.Fatalf("trimSafe %v: got %+v and %d layers", trimSafe, header, len(layers))
+			}
+			if !reflect.DeepEqual(layers[0].Grid, want) {
+				t.Fatalf("trimSafe %v: got %q, want %q\n%q", trimSafe, rows(layers[0].Grid), rows(want), b.String())
+			}
+			if !reflect.DeepEqual(layers[1].Grid, NewLayer(w, h).Grid) {
+				t.Fatalf("trimSafe %v: the top layer isn't transparent", trimSafe)
+			}
+		}
+	})
+}
+
+//
+// The lines of s with trailing whitespace stripped, the way some editors
+// save them.
+//
+func stripTrailing(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTrimSafe(t *testing.T) {
+	c := canvasOf(
+		"ab  ",
+		"    ",
+		" 漢 ",
+		"abcd",
+	)
+	c.Set(3, 3, Pixel{R: ' ', BG: "1"})
+	layers := []Layer{{c, true}, NewLayer(4, 4)}
+
+	var b strings.Builder
+	if err := Save(layers, 4, 4, nil, true, &b); err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if strings.HasSuffix(line, " ") {
+			t.Errorf("line %d ends in a space: %q", i + 1, line)
+		}
+	}
+	_, loaded, err := Load(strings.NewReader(stripTrailing(b.String())))
+	if err != nil {
+		t.Fatalf("stripped: %v\n%q", err, b.String())
+	}
+	if !reflect.DeepEqual(loaded, layers) {
+		t.Errorf("stripped: got %q, want %q", rows(loaded[0].Grid), rows(c))
+	}
+
+	//
+	// Without it, stripping loses the spaces that make up a row.
+	//
+	b.Reset()
+	if err := Save(layers, 4, 4, nil, false, &b); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load(strings.NewReader(stripTrailing(b.String()))); err == nil {
+		t.Errorf("loaded a stripped file saved without trimSafe")
+	}
+}
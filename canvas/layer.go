@@ -0,0 +1,43 @@
+package canvas
+
+//
+// The zero pixel is transparent: it lets the layers below show through.
+//
+var Transparent = Pixel{}
+
+type Layer struct {
+	Grid Canvas
+	Visible bool
+}
+
+func NewLayer(width, height int) Layer {
+	return Layer{Filled(width, height, Transparent), true}
+}
+
+func CloneLayers(src []Layer) []Layer {
+	dst := make([]Layer, len(src))
+	for i := range src {
+		dst[i] = Layer{src[i].Grid.Clone(), src[i].Visible}
+	}
+	return dst
+}
+
+//
+// Flatten the visible layers into a single grid, taking the topmost
+// non-transparent pixel at each position.  Cells that are transparent all
+// the way down come out as spaces.
+//
+func Composite(layers []Layer, width, height int) Canvas {
+	out := New(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for i := len(layers) - 1; i >= 0; i-- {
+				if p := layers[i].Grid.At(x, y); layers[i].Visible && p != Transparent {
+					out.cells[y][x] = p
+					break
+				}
+			}
+		}
+	}
+	return out
+}
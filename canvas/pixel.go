@@ -0,0 +1,58 @@
+package canvas
+
+import "github.com/mattn/go-runewidth"
+
+type Pixel struct {
+	R rune
+	FG Color
+	BG Color
+	Attrs Attrs
+}
+
+//
+// The same colors and attributes, with a different glyph.
+//
+func (p Pixel) WithRune(r rune) Pixel {
+	p.R = r
+	return p
+}
+
+//
+// Just the colors and attributes, for comparing the look of cells.
+//
+func (p Pixel) Style() Pixel {
+	return p.WithRune(0)
+}
+
+//
+// Whether p shows nothing: transparency, padding or a plain space.  A
+// space with a background color is a solid block, so it isn't blank.
+//
+func (p Pixel) IsBlank() bool {
+	return p == Transparent || p == Padding || p == Pixel{R: ' '}
+}
+
+//
+// Wide glyphs (CJK, most emoji) take up two terminal columns.  On the
+// canvas, they occupy their own cell plus the one to the right of it,
+// which holds the padding pixel.  Padding is never written out: the wide
+// glyph covers that column on screen and in saved files alike.
+//
+const PaddingRune rune = -1
+
+var Padding = Pixel{R: PaddingRune}
+
+func IsWide(r rune) bool {
+	return r != PaddingRune && runewidth.RuneWidth(r) == 2
+}
+
+//
+// The x coordinate of the cell that owns the column at x, which is the
+// wide glyph to the left when x is padding.
+//
+func CellOwner(row []Pixel, x int) int {
+	if x > 0 && x < len(row) && row[x] == Padding && IsWide(row[x-1].R) {
+		return x - 1
+	}
+	return x
+}
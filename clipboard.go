@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Text from outside gopnik to draw at the cursor, either from the system
+// clipboard or pasted into the terminal.
+//
+type pourMsg struct {
+	text string
+}
+
+func readClipboard() tea.Msg {
+	text, err := clipboard.ReadAll()
+	if err != nil {
+		return errMsg{fmt.Errorf("reading the clipboard: %w", err)}
+	}
+	return pourMsg{text}
+}
+
+//
+// Draw text with its top-left corner at the cursor, a line per row, growing
+// the canvas if it doesn't fit.  Escape sequences and control characters
+// are dropped, the way they are when importing a text file.
+//
+func (m *model) pour(text string) error {
+	region, err := importText(strings.NewReader(canvas.StripEscapes(text)), m.tabWidth)
+	if err != nil {
+		return err
+	}
+	width, height := region.Bounds()
+	if height == 0 {
+		return fmt.Errorf("nothing to paste")
+	}
+	x, y := m.cursorX, m.cursorY
+	newW := min(max(m.width, x + width), maxCanvasWidth)
+	newH := min(max(m.height, y + height), maxCanvasHeight)
+	if newW != m.width || newH != m.height {
+		m.resize(newW, newH)
+	} else {
+		m.pushHistory()
+	}
+	pasteRegion(m.canvas(), region.Cells(), x, y, m.transparentBlanks)
+	return nil
+}
+
+//
+// The canvas as plain text for pasting elsewhere: rendered like Canvas.Dump
+// but without colors, and with the trailing spaces of each line trimmed.
+//
+func plainText(c Canvas) string {
+	var b strings.Builder
+	row := make([]pixel, c.Width())
+	for y := 0; y < c.Height(); y++ {
+		for x := range row {
+			row[x] = pixel{R: c.At(x, y).R}
+		}
+		b.WriteString(strings.TrimRight(canvas.FormatRow(row), " "))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func yank(canvas Canvas) tea.Msg {
+	if err := clipboard.WriteAll(plainText(canvas)); err != nil {
+		return errMsg{fmt.Errorf("writing the clipboard: %w", err)}
+	}
+	return statusMsg{fmt.Sprintf("copied %dx%d to the clipboard", canvas.Width(), canvas.Height())}
+}
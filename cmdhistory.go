@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//
+// How many past commands to load from the history file.
+//
+const maxCommandHistory = 1000
+
+func commandHistoryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopnik", "history"), nil
+}
+
+//
+// The commands from previous sessions, oldest first.  A missing or
+// unreadable history file just means there's no history yet.
+//
+func loadCommandHistory() []string {
+	path, err := commandHistoryPath()
+	if err != nil {
+		return nil
+	}
+	fin, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer fin.Close()
+
+	var history []string
+	scanner := bufio.NewScanner(fin)
+	for scanner.Scan() {
+		history = append(history, scanner.Text())
+	}
+	if len(history) > maxCommandHistory {
+		history = history[len(history)-maxCommandHistory:]
+	}
+	return history
+}
+
+func appendCommandHistory(command string) tea.Cmd {
+	return func() tea.Msg {
+		path, err := commandHistoryPath()
+		if err != nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil
+		}
+		fout, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0o644)
+		if err != nil {
+			return nil
+		}
+		defer fout.Close()
+		fout.WriteString(command + "\n")
+		return nil
+	}
+}
+
+//
+// Remember an executed command, skipping repeats of the previous one.
+// Returns a tea.Cmd that persists it, or nil if there's nothing to do.
+//
+func (m *model) rememberCommand(command string) tea.Cmd {
+	if command == "" {
+		return nil
+	}
+	if n := len(m.commandHistory); n > 0 && m.commandHistory[n-1] == command {
+		return nil
+	}
+	m.commandHistory = append(m.commandHistory, command)
+	return appendCommandHistory(command)
+}
+
+//
+// Step through the history, where the position just past the end is the
+// empty command line.
+//
+func (m *model) recallCommand(delta int) {
+	cursor := m.commandHistoryCursor + delta
+	if cursor < 0 || cursor > len(m.commandHistory) {
+		return
+	}
+	m.commandHistoryCursor = cursor
+	if cursor == len(m.commandHistory) {
+		m.commandBuffer = ""
+	} else {
+		m.commandBuffer = m.commandHistory[cursor]
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "strconv"
+
+//
+// The color picker is drawn in the top-left corner like the palette: a grid
+// of swatches, each two cells of background color, with the 16 basic
+// colors in two rows of 8, or all 256 in rows of 16.  Painting the
+// background rather than a block glyph looks the same in any font.
+//
+const swatchWidth = 2
+
+func colorGridColumns(count int) int {
+	if count <= 16 {
+		return 8
+	}
+	return 16
+}
+
+func drawColorGrid(canvas Canvas, count int) {
+	columns := colorGridColumns(count)
+	for i := 0; i < count; i++ {
+		x, y := (i % columns) * swatchWidth, i / columns
+		for dx := 0; dx < swatchWidth; dx++ {
+			canvas.Set(x + dx, y, pixel{R: ' ', BG: color(strconv.Itoa(i))})
+		}
+	}
+}
+
+//
+// The color of the swatch at (x, y), if any.
+//
+func colorGridAt(count, x, y int) (color, bool) {
+	columns := colorGridColumns(count)
+	if x < 0 || y < 0 || x >= columns * swatchWidth {
+		return noColor, false
+	}
+	if i := y * columns + x / swatchWidth; i < count {
+		return color(strconv.Itoa(i)), true
+	}
+	return noColor, false
+}
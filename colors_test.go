@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func TestColorGrid(t *testing.T) {
+	for _, count := range []int{16, 256} {
+		c := canvas.Filled(32, 16, transparent)
+		drawColorGrid(c, count)
+		for i := 0; i < count; i++ {
+			columns := colorGridColumns(count)
+			x, y := (i % columns) * swatchWidth, i / columns
+			want := pixel{R: ' ', BG: color(strconv.Itoa(i))}
+			for dx := 0; dx < swatchWidth; dx++ {
+				if p := c.At(x + dx, y); p != want {
+					t.Fatalf("%d colors: swatch %d is %+v, want %+v", count, i, p, want)
+				}
+				if got, ok := colorGridAt(count, x + dx, y); !ok || got != want.BG {
+					t.Errorf("%d colors: clicking swatch %d got %q, %v", count, i, got, ok)
+				}
+			}
+		}
+	}
+	if _, ok := colorGridAt(16, 16, 0); ok {
+		t.Errorf("a click past the swatches picked a color")
+	}
+	if _, ok := colorGridAt(16, 0, 2); ok {
+		t.Errorf("a click below the swatches picked a color")
+	}
+}
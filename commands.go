@@ -0,0 +1,830 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// What a verb typed after : does.  Commands run in their own goroutine, so
+// they get a copy of the model to read, and change things by returning a
+// message for Update.
+//
+type command struct {
+	//
+	// What the command does without an argument, and with one.  Either
+	// may be nil: a command without bare needs an argument if it has a
+	// usage, and gets an empty one otherwise; a command without run
+	// doesn't take one at all.
+	//
+	bare func(m model) tea.Msg
+	run func(m model, arg string) tea.Msg
+
+	usage string
+}
+
+//
+// Returned by commands to have the usage shown.
+//
+var errUsage = errors.New("bad usage")
+
+//
+// For commands that always do the same thing.
+//
+func always(msg tea.Msg) func(model) tea.Msg {
+	return func(model) tea.Msg {
+		return msg
+	}
+}
+
+//
+// For commands that take on or off.
+//
+func onOff(msg func(on bool) tea.Msg) func(model, string) tea.Msg {
+	return func(m model, arg string) tea.Msg {
+		switch arg {
+		case "on":
+			return msg(true)
+		case "off":
+			return msg(false)
+		}
+		return errMsg{errUsage}
+	}
+}
+
+//
+// Options for :set, which are seldom changed and don't get a command each.
+//
+var setOptions = map[string]func(on bool) tea.Msg{
+	"trimsafe": func(on bool) tea.Msg { return trimSafeChangedMsg{on} },
+}
+
+func setCommand(m model, arg string) tea.Msg {
+	name, value, _ := strings.Cut(arg, " ")
+	option, ok := setOptions[name]
+	if !ok {
+		return errMsg{fmt.Errorf("unknown option %q", name)}
+	}
+	return onOff(option)(m, strings.TrimSpace(value))
+}
+
+//
+// Both numbers of e.g. "80 50", or false if that's not what arg is.
+//
+func twoInts(arg string) (a, b int, ok bool) {
+	args := strings.Fields(arg)
+	if len(args) != 2 {
+		return 0, 0, false
+	}
+	a, erra := strconv.Atoi(args[0])
+	b, errb := strconv.Atoi(args[1])
+	return a, b, erra == nil && errb == nil
+}
+
+//
+// The width and height given to verb, e.g. "120 40", no bigger than a
+// file can hold, so that a typo can't run out of memory.
+//
+func canvasSize(verb, arg string) (width, height int, err error) {
+	width, height, ok := twoInts(arg)
+	if !ok || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("bad %s command %q", verb, arg)
+	} else if width > maxCanvasWidth || height > maxCanvasHeight {
+		return 0, 0, fmt.Errorf("the canvas can't be more than %dx%d", maxCanvasWidth, maxCanvasHeight)
+	}
+	return width, height, nil
+}
+
+//
+// Every verb, by name.
+//
+var commands = map[string]command{
+	"q": {bare: always(quitMsg{}), run: func(model, string) tea.Msg { return quitMsg{} }},
+	"quit": {bare: always(quitMsg{}), run: func(model, string) tea.Msg { return quitMsg{} }},
+	"q!": {bare: always(quitMsg{true})},
+	"quit!": {bare: always(quitMsg{true})},
+	"undo": {bare: always(undoMsg{})},
+	"redo": {bare: always(redoMsg{})},
+	"line": {bare: always(toolArmedMsg{toolLine})},
+	"rect": {bare: always(toolArmedMsg{toolRect})},
+	"rectfill": {bare: always(toolArmedMsg{toolRectFill})},
+	"ellipse": {bare: always(toolArmedMsg{toolEllipse})},
+	"ellipsefill": {bare: always(toolArmedMsg{toolEllipseFill})},
+	"poly": {bare: always(polyArmedMsg{false}), run: polyCommand, usage: ":poly [closed]"},
+	"select": {bare: always(toolArmedMsg{toolSelect})},
+	"paste": {bare: always(toolArmedMsg{toolPaste})},
+	"pick": {bare: always(toolArmedMsg{toolPick})},
+	"text": {bare: always(toolArmedMsg{toolText})},
+	"copy": {bare: always(copyMsg{false})},
+	"cut": {bare: always(copyMsg{true})},
+	"clear": {bare: always(clearMsg{})},
+	"palette": {bare: always(paletteToggledMsg{})},
+	"minimap": {bare: always(minimapToggledMsg{})},
+	"fliph": {bare: always(transformMsg{"fliph"})},
+	"flipv": {bare: always(transformMsg{"flipv"})},
+	"rotate": {bare: always(transformMsg{"rotate"})},
+	"tabnext": {bare: always(tabSwitchMsg{1})},
+	"tabprev": {bare: always(tabSwitchMsg{-1})},
+	"tabclose": {bare: always(tabCloseMsg{})},
+	"tabclose!": {bare: always(tabCloseMsg{true})},
+	"trim": {bare: always(trimMsg{})},
+	"fillsel": {bare: always(fillSelectionMsg{})},
+	"erase": {bare: always(eraseToggledMsg{})},
+	"pour": {bare: func(model) tea.Msg { return readClipboard() }},
+	"recover": {bare: func(m model) tea.Msg { return recoverBackup(m.filename, m.activeTab) }},
+	"info": {bare: info},
+	"yank": {bare: func(m model) tea.Msg { return yank(canvas.Composite(m.layers, m.width, m.height)) }},
+
+	"s": {bare: saveAgain(":save <file>"), run: save},
+	"save": {bare: saveAgain(":save <file>"), run: save},
+	"w": {bare: saveAgain(":write <file>"), run: save},
+	"write": {bare: saveAgain(":write <file>"), run: save},
+	"saveas": {run: save, usage: ":saveas <file>"},
+	"mksession": {run: saveSession, usage: ":mksession <file>"},
+	"l": {run: func(m model, arg string) tea.Msg { return loadFile(arg) }, usage: ":load <file>"},
+	"load": {run: func(m model, arg string) tea.Msg { return loadFile(arg) }, usage: ":load <file>"},
+	"tabnew": {run: func(m model, arg string) tea.Msg { return tabNewMsg{arg} }},
+	"open": {
+		run: func(m model, arg string) tea.Msg { return openImage(arg, m.width, m.height, m.shadeRamp) },
+		usage: ":open <image> [dither] [color]",
+	},
+	"import": {run: importCommand, usage: ":import <file>"},
+	"export": {run: exportCommand, usage: ":export <file.svg|file.html|file.ans>"},
+	"stamp": {bare: stampClipboard, run: stampFile},
+
+	"b": {run: brushCommand, usage: ":brush [1|2] <char|U+XXXX|name>"},
+	"brush": {run: brushCommand, usage: ":brush [1|2] <char|U+XXXX|name>"},
+	"brushes": {bare: listBrushes},
+	"c": {run: colorCommand, usage: ":color <name|#rrggbb|0-255>"},
+	"color": {run: colorCommand, usage: ":color <name|#rrggbb|0-255>"},
+	"bg": {
+		run: func(m model, arg string) tea.Msg {
+			c, err := canvas.ParseColor(arg)
+			if err != nil {
+				return errMsg{err}
+			}
+			return bgChangedMsg{c}
+		},
+		usage: ":bg <name|#rrggbb|0-255>",
+	},
+	"size": {
+		run: func(m model, arg string) tea.Msg {
+			size, err := strconv.Atoi(arg)
+			if err != nil || size < 1 || size > maxBrushSize {
+				return errMsg{fmt.Errorf("bad brush size %q", arg)}
+			}
+			return brushSizeChangedMsg{size}
+		},
+		usage: ":size <n>",
+	},
+	"shape": {
+		run: func(m model, arg string) tea.Msg {
+			shape, err := parseBrushShape(arg)
+			if err != nil {
+				return errMsg{err}
+			}
+			return brushShapeChangedMsg{shape}
+		},
+		usage: ":shape <square|circle>",
+	},
+	"bold": {run: attrCommand(attrBold), usage: ":bold <on|off>"},
+	"underline": {run: attrCommand(attrUnderline), usage: ":underline <on|off>"},
+	"blink": {run: attrCommand(attrBlink), usage: ":blink <on|off>"},
+	"reverse": {run: attrCommand(attrReverse), usage: ":reverse <on|off>"},
+
+	"wrap": {
+		run: onOff(func(on bool) tea.Msg { return wrapChangedMsg{on} }),
+		usage: ":wrap <on|off>",
+	},
+	"set": {run: setCommand, usage: ":set trimsafe <on|off>"},
+	"snap": {
+		run: onOff(func(on bool) tea.Msg { return snapChangedMsg{on} }),
+		usage: ":snap <on|off>",
+	},
+	"ink": {
+		run: func(m model, arg string) tea.Msg {
+			opacity, err := strconv.ParseFloat(arg, 64)
+			if err != nil || opacity <= 0 || opacity > 1 {
+				return errMsg{fmt.Errorf("bad ink %q: expected more than 0, up to 1", arg)}
+			}
+			return inkChangedMsg{opacity}
+		},
+		usage: ":ink <0..1>",
+	},
+	"dangling": {
+		run: onOff(func(on bool) tea.Msg { return danglingChangedMsg{on} }),
+		usage: ":dangling <on|off>",
+	},
+	"smooth": {
+		run: onOff(func(on bool) tea.Msg { return smoothChangedMsg{on} }),
+		usage: ":smooth <on|off>",
+	},
+	"ortho": {
+		run: onOff(func(on bool) tea.Msg { return orthoChangedMsg{on} }),
+		usage: ":ortho <on|off>",
+	},
+	"autoconnect": {
+		run: onOff(func(on bool) tea.Msg { return autoConnectChangedMsg{on} }),
+		usage: ":autoconnect <on|off>",
+	},
+	"transparent": {
+		run: onOff(func(on bool) tea.Msg { return transparentBlanksMsg{on} }),
+		usage: ":transparent <on|off>",
+	},
+	"mirror": {
+		run: func(m model, arg string) tea.Msg {
+			mode, err := parseMirrorMode(arg)
+			if err != nil {
+				return errMsg{err}
+			}
+			return mirrorChangedMsg{mode}
+		},
+		usage: ":mirror <none|x|y|xy>",
+	},
+	"autosave": {
+		run: func(m model, arg string) tea.Msg {
+			if arg == "off" {
+				return autosaveChangedMsg{0}
+			}
+			seconds, err := strconv.Atoi(arg)
+			if err != nil || seconds < 0 {
+				return errMsg{fmt.Errorf("bad autosave interval %q", arg)}
+			}
+			return autosaveChangedMsg{time.Duration(seconds) * time.Second}
+		},
+		usage: ":autosave <seconds|off>",
+	},
+	"tabwidth": {
+		run: func(m model, arg string) tea.Msg {
+			width, err := strconv.Atoi(arg)
+			if err != nil || width < 1 {
+				return errMsg{fmt.Errorf("bad tab width %q", arg)}
+			}
+			return tabWidthMsg{width}
+		},
+		usage: ":tabwidth <n>",
+	},
+
+	"fill": {
+		bare: func(m model) tea.Msg { return fillArmedMsg{m.fillDiagonal} },
+		run: func(m model, arg string) tea.Msg {
+			//
+			// 8-connected fills leak through diagonal gaps, 4-connected
+			// don't.
+			//
+			switch arg {
+			case "4":
+				return fillArmedMsg{false}
+			case "8":
+				return fillArmedMsg{true}
+			}
+			return errMsg{fmt.Errorf("bad fill connectivity %q", arg)}
+		},
+	},
+	"border": {
+		bare: always(borderMsg{borderStyles["single"]}),
+		run: func(m model, arg string) tea.Msg {
+			glyphs, ok := borderStyles[arg]
+			if !ok {
+				return errMsg{fmt.Errorf("bad border style %q", arg)}
+			}
+			return borderMsg{glyphs}
+		},
+	},
+	"gradient": {bare: always(gradientArmedMsg{gradientHorizontal, nil}), run: gradientCommand},
+	"spray": {bare: always(sprayArmedMsg{}), run: sprayCommand},
+	"colors": {
+		bare: always(colorsToggledMsg{16}),
+		run: func(m model, arg string) tea.Msg {
+			switch arg {
+			case "16":
+				return colorsToggledMsg{16}
+			case "256":
+				return colorsToggledMsg{256}
+			}
+			return errMsg{fmt.Errorf("bad color count %q: expected 16 or 256", arg)}
+		},
+	},
+	"layer": {run: layerCommand, usage: ":layer <new|n|hide n|show n>"},
+	"move": {
+		bare: always(moveModeMsg{}),
+		run: func(m model, arg string) tea.Msg {
+			args := strings.Fields(arg)
+			if len(args) == 2 || (len(args) == 3 && args[2] == "wrap") {
+				if dx, dy, ok := twoInts(strings.Join(args[:2], " ")); ok {
+					return layerMoveMsg{dx, dy, len(args) == 3}
+				}
+			}
+			return errMsg{fmt.Errorf("bad move command %q", arg)}
+		},
+	},
+	"replace": {
+		run: func(m model, arg string) tea.Msg {
+			args := strings.Fields(arg)
+			if len(args) != 2 {
+				return errMsg{errUsage}
+			}
+			from, err := parseBrushRune(args[0])
+			if err != nil {
+				return errMsg{err}
+			}
+			to, err := parseBrushRune(args[1])
+			if err != nil {
+				return errMsg{err}
+			}
+			return replaceMsg{from, to}
+		},
+		usage: ":replace <char|U+XXXX> <char|U+XXXX>",
+	},
+	"scroll": {
+		run: func(m model, arg string) tea.Msg {
+			if dx, dy, ok := twoInts(arg); ok {
+				return scrollMsg{dx, dy}
+			}
+			return errMsg{fmt.Errorf("bad scroll command %q", arg)}
+		},
+		usage: ":scroll <dx> <dy>",
+	},
+	"resize": {
+		run: func(m model, arg string) tea.Msg {
+			width, height, err := canvasSize("resize", arg)
+			if err != nil {
+				return errMsg{err}
+			}
+			return resizeMsg{width, height, anchors["topleft"]}
+		},
+		usage: ":resize <width> <height>",
+	},
+	"canvas": {run: canvasCommand, usage: ":canvas <width> <height> [anchor]"},
+	"new": {
+		run: func(m model, arg string) tea.Msg {
+			width, height, err := canvasSize("new", arg)
+			if err != nil {
+				return errMsg{err}
+			}
+			return newCanvasMsg{width, height}
+		},
+		usage: ":new <width> <height>",
+	},
+	"goto": {
+		run: func(m model, arg string) tea.Msg {
+			if x, y, ok := twoInts(arg); ok {
+				return gotoMsg{x, y}
+			} else if p, ok := m.marks[arg]; ok {
+				return gotoMsg{p.X, p.Y}
+			} else if !strings.ContainsAny(arg, " \t") {
+				return errMsg{fmt.Errorf("no mark %q, see :mark", arg)}
+			}
+			return errMsg{fmt.Errorf("bad goto command %q", arg)}
+		},
+		usage: ":goto <x y|mark>",
+	},
+	"record": {run: recordCommand, usage: ":record <register|end>"},
+	"play": {run: playCommand, usage: ":play <register> [count]"},
+	"key": {run: keyCommand, usage: ":key <name>"},
+	"mark": {bare: listMarks, run: markCommand, usage: ":mark [name]"},
+	"grid": {run: gridCommand, usage: ":grid <n|off> [char]"},
+	"insrow": {run: spliceCommand(false, true), usage: ":insrow <y>"},
+	"delrow": {run: spliceCommand(false, false), usage: ":delrow <y>"},
+	"inscol": {run: spliceCommand(true, true), usage: ":inscol <x>"},
+	"delcol": {run: spliceCommand(true, false), usage: ":delcol <x>"},
+	"source": {
+		run: func(m model, filename string) tea.Msg {
+			lines, err := readScript(filename)
+			if err != nil {
+				return errMsg{err}
+			} else if len(lines) == 0 {
+				return statusMsg{filename + " has no commands"}
+			}
+			return sourceMsg{filename, lines}
+		},
+		usage: ":source <file>",
+	},
+}
+
+//
+// Run a command line, e.g. "brush 2 U+2588", returning the message that
+// it results in.
+//
+func runCommand(m model, line string) tea.Msg {
+	verb, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	name, err := commandName(verb)
+	if err != nil {
+		return errMsg{err}
+	}
+	c := commands[name]
+	if arg != "" && c.run == nil {
+		return errMsg{fmt.Errorf("%q takes no argument", name)}
+	}
+
+	var msg tea.Msg
+	if arg == "" && c.bare != nil {
+		msg = c.bare(m)
+	} else if arg == "" && c.usage != "" {
+		return errMsg{fmt.Errorf("usage: %s", c.usage)}
+	} else {
+		msg = c.run(m, arg)
+	}
+	if err, ok := msg.(errMsg); ok && err.err == errUsage {
+		return errMsg{fmt.Errorf("usage: %s", c.usage)}
+	}
+	return msg
+}
+
+//
+// The full name of the command that verb names or abbreviates.  Like in
+// vim, any prefix will do as long as it's unambiguous, and a verb wins over
+// the longer ones that it's a prefix of, so that "sa" is save rather than
+// saveas.  A ! stays put: "tabc!" is tabclose!.
+//
+func commandName(verb string) (string, error) {
+	if _, ok := commands[verb]; ok {
+		return verb, nil
+	}
+	prefix, bang := strings.CutSuffix(verb, "!")
+	var candidates []string
+	for name := range commands {
+		base, nameBang := strings.CutSuffix(name, "!")
+		if nameBang == bang && strings.HasPrefix(base, prefix) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 || prefix == "" {
+		return "", fmt.Errorf("unknown command %q", verb)
+	}
+	sort.Strings(candidates)
+	for _, name := range candidates[1:] {
+		if !strings.HasPrefix(name, strings.TrimSuffix(candidates[0], "!")) {
+			return "", fmt.Errorf("ambiguous command %q: %s", verb, strings.Join(candidates, ", "))
+		}
+	}
+	return candidates[0], nil
+}
+
+//
+// One command of several typed together, e.g. ":border | grid 5 | save
+// out.txt", or read from a script, along with what it resulted in and the
+// commands still to run.
+//
+type chainMsg struct {
+	script string
+	line commandLine
+	msg tea.Msg
+	rest []commandLine
+
+	//
+	// Whether the whole chain is one undo step, as when a macro plays.
+	//
+	grouped bool
+}
+
+type sourceMsg struct {
+	script string
+	lines []commandLine
+}
+
+//
+// A command and where it came from: its position on the command line, or
+// its line number in a script.
+//
+type commandLine struct {
+	n int
+	text string
+}
+
+//
+// The commands in buffer, which are separated by | or newlines.  A
+// literal | is written \|, e.g. ":brush \|".
+//
+func splitCommands(buffer string) []string {
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if l := strings.TrimSpace(line.String()); l != "" {
+			lines = append(lines, l)
+		}
+		line.Reset()
+	}
+	for i := 0; i < len(buffer); i++ {
+		if buffer[i] == '\\' && i + 1 < len(buffer) && buffer[i+1] == '|' {
+			line.WriteByte('|')
+			i++
+		} else if buffer[i] == '|' || buffer[i] == '\n' {
+			flush()
+		} else {
+			line.WriteByte(buffer[i])
+		}
+	}
+	flush()
+	return lines
+}
+
+func interpretCmd(m model, buffer string) tea.Cmd {
+	split := splitCommands(buffer)
+	if len(split) <= 1 {
+		line := buffer
+		if len(split) == 1 {
+			line = split[0]
+		}
+		return func() tea.Msg {
+			return runCommand(m, line)
+		}
+	}
+	lines := make([]commandLine, len(split))
+	for i, text := range split {
+		lines[i] = commandLine{i + 1, text}
+	}
+	return runChain(m, "", lines)
+}
+
+//
+// Run the first of lines against m.  The rest wait until Update has
+// applied the result, so that each command sees what the ones before it
+// did.
+//
+func runChain(m model, script string, lines []commandLine) tea.Cmd {
+	return nextInChain(m, chainMsg{script: script, rest: lines})
+}
+
+//
+// Run the next command of chain, which must have one left.
+//
+func nextInChain(m model, chain chainMsg) tea.Cmd {
+	return func() tea.Msg {
+		chain.line, chain.rest = chain.rest[0], chain.rest[1:]
+		chain.msg = runCommand(m, chain.line.text)
+		return chain
+	}
+}
+
+//
+// Apply one command of a chain and carry on with the next, unless it
+// failed.  What a command starts in the background, like loading a file,
+// isn't waited for.
+//
+func (m model) continueChain(msg chainMsg) (tea.Model, tea.Cmd) {
+	if err, ok := msg.msg.(errMsg); ok {
+		if msg.grouped {
+			m.endHistoryGroup()
+		}
+		errorf("%s: %v", msg.where(), err.err)
+		if msg.script != "" {
+			return m, m.setStatus(fmt.Sprintf("%s: %v", msg.where(), err.err), true)
+		}
+		return m, m.setStatus(fmt.Sprintf("command %d (%s) failed: %v", msg.line.n, msg.line.text, err.err), true)
+	}
+	next, cmd := m.Update(msg.msg)
+	m = next.(model)
+	if _, ok := msg.msg.(quitMsg); ok || len(msg.rest) == 0 {
+		if msg.grouped {
+			m.endHistoryGroup()
+		}
+		return m, cmd
+	}
+	return m, tea.Batch(cmd, nextInChain(m, msg))
+}
+
+//
+// E.g. "logo.gop:12" for a script, "command 3" otherwise.
+//
+func (msg chainMsg) where() string {
+	if msg.script != "" {
+		return fmt.Sprintf("%s:%d", msg.script, msg.line.n)
+	}
+	return fmt.Sprintf("command %d", msg.line.n)
+}
+
+func save(m model, filename string) tea.Msg {
+	if filename == stdioName {
+		return saveStdout(m.layers, m.width, m.height, m.marks, m.trimSafe)
+	}
+	fout, err := os.OpenFile(filename, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, 0o644)
+	if err != nil {
+		return errMsg{err}
+	}
+	defer fout.Close()
+
+	if err := canvas.Save(m.layers, m.width, m.height, m.marks, m.trimSafe, fout); err != nil {
+		return errMsg{err}
+	}
+	return savedMsg{filename}
+}
+
+//
+// Like in vim, saving without a filename uses the remembered one.
+//
+func saveAgain(usage string) func(model) tea.Msg {
+	return func(m model) tea.Msg {
+		if m.filename == "" {
+			return errMsg{fmt.Errorf("usage: %s", usage)}
+		}
+		return save(m, m.filename)
+	}
+}
+
+func importCommand(m model, filename string) tea.Msg {
+	fin, err := os.Open(filename)
+	if err != nil {
+		return errMsg{err}
+	}
+	defer fin.Close()
+
+	canvas, err := importText(fin, m.tabWidth)
+	if err != nil {
+		return errMsg{err}
+	}
+	width, height := canvas.Bounds()
+	if width == 0 || height == 0 {
+		return errMsg{fmt.Errorf("%q is empty", filename)}
+	}
+
+	//
+	// Don't remember the name: saving would turn the text file into a
+	// gopnik file.
+	//
+	return canvasLoadedMsg{width, height, []layer{{Grid: canvas, Visible: true}}, "", nil}
+}
+
+func exportCommand(m model, filename string) tea.Msg {
+	ext := strings.ToLower(filepath.Ext(filename))
+	render, ok := exporters[ext]
+	if !ok {
+		return errMsg{fmt.Errorf("don't know how to export %q", filename)}
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if ext == ".ans" {
+		//
+		// Each export adds a frame, so that a recording can be built up
+		// and played back with cat.
+		//
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	fout, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return errMsg{err}
+	}
+	defer fout.Close()
+
+	if err := render(canvas.Composite(m.layers, m.width, m.height), fout); err != nil {
+		return errMsg{err}
+	}
+	return statusMsg{"exported " + filename}
+}
+
+//
+// Without a file, stamp whatever was copied last.
+//
+func stampClipboard(m model) tea.Msg {
+	if m.clipboard == nil {
+		return errMsg{fmt.Errorf("nothing copied to stamp with")}
+	}
+	return stampMsg{m.clipboard}
+}
+
+func stampFile(m model, filename string) tea.Msg {
+	fin, err := os.Open(filename)
+	if err != nil {
+		return errMsg{err}
+	}
+	defer fin.Close()
+
+	h, layers, _, err := readArt(fin, m.tabWidth)
+	if err != nil {
+		return errMsg{fmt.Errorf("%s: %w", filename, err)}
+	}
+	return stampMsg{canvas.Composite(layers, h.Width, h.Height).Cells()}
+}
+
+func brushCommand(m model, arg string) tea.Msg {
+	slot, r, err := parseBrushArg(arg)
+	if err != nil {
+		return errMsg{err}
+	}
+	current := m.brushPrimary
+	if slot == 2 {
+		current = m.brushSecondary
+	}
+	return brushChangedMsg{current.WithRune(r), slot}
+}
+
+func colorCommand(m model, arg string) tea.Msg {
+	c, err := canvas.ParseColor(arg)
+	if err != nil {
+		return errMsg{err}
+	}
+	return colorChangedMsg{c}
+}
+
+func attrCommand(attr attrs) func(model, string) tea.Msg {
+	return onOff(func(on bool) tea.Msg {
+		return attrChangedMsg{attr, on}
+	})
+}
+
+//
+// The ramp is whatever follows the direction, e.g. "gradient v .:-=+*#%@".
+//
+func gradientCommand(m model, arg string) tea.Msg {
+	args := strings.SplitN(arg, " ", 2)
+	direction, err := parseGradientDirection(args[0])
+	if err != nil {
+		return errMsg{err}
+	}
+	var ramp []rune
+	if len(args) == 2 {
+		ramp = []rune(args[1])
+	}
+	return gradientArmedMsg{direction, ramp}
+}
+
+func sprayCommand(m model, arg string) tea.Msg {
+	args := strings.Fields(arg)
+	if len(args) != 2 {
+		return errMsg{fmt.Errorf("usage: :spray <radius> <density>")}
+	}
+	radius, err := strconv.Atoi(args[0])
+	if err != nil || radius < 0 || radius > maxSprayRadius {
+		return errMsg{fmt.Errorf("bad spray radius %q: expected 0-%d", args[0], maxSprayRadius)}
+	}
+	density, err := strconv.Atoi(args[1])
+	if err != nil || density < 1 || density > maxSprayDensity {
+		return errMsg{fmt.Errorf("bad spray density %q: expected 1-%d", args[1], maxSprayDensity)}
+	}
+	return sprayArmedMsg{radius, density}
+}
+
+//
+// Layers are numbered from 1, bottom to top.
+//
+func layerCommand(m model, arg string) tea.Msg {
+	args := strings.Fields(arg)
+	if len(args) == 1 && args[0] == "new" {
+		return layerNewMsg{}
+	} else if len(args) == 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			return layerSelectMsg{n - 1}
+		}
+	} else if len(args) == 2 && (args[0] == "hide" || args[0] == "show") {
+		if n, err := strconv.Atoi(args[1]); err == nil {
+			return layerVisibilityMsg{n - 1, args[0] == "show"}
+		}
+	}
+	return errMsg{fmt.Errorf("bad layer command %q", arg)}
+}
+
+func canvasCommand(m model, arg string) tea.Msg {
+	args := strings.Fields(arg)
+	if len(args) == 2 || len(args) == 3 {
+		a, ok := anchors["center"], true
+		if len(args) == 3 {
+			a, ok = anchors[args[2]]
+		}
+		if !ok {
+			return errMsg{fmt.Errorf("bad anchor %q", args[2])}
+		}
+		width, height, err := canvasSize("canvas", strings.Join(args[:2], " "))
+		if err != nil {
+			return errMsg{err}
+		}
+		return resizeMsg{width, height, a}
+	}
+	return errMsg{fmt.Errorf("bad canvas command %q", arg)}
+}
+
+func spliceCommand(column, insert bool) func(model, string) tea.Msg {
+	return func(m model, arg string) tea.Msg {
+		at, err := strconv.Atoi(arg)
+		if err != nil {
+			return errMsg{errUsage}
+		}
+		return spliceMsg{column, at, insert}
+	}
+}
+
+func gridCommand(m model, arg string) tea.Msg {
+	args := strings.Fields(arg)
+	if len(args) == 1 && args[0] == "off" {
+		return gridMsg{0, m.gridRune}
+	} else if len(args) == 1 || len(args) == 2 {
+		size, err := strconv.Atoi(args[0])
+		if err == nil && size > 0 {
+			r := m.gridRune
+			if len(args) == 2 {
+				r = []rune(args[1])[0]
+			}
+			return gridMsg{size, r}
+		}
+	}
+	return errMsg{fmt.Errorf("bad grid command %q", arg)}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//
+// A headless editor with a blank width x height canvas, for running
+// commands against.
+//
+func testModel(width, height int) model {
+	return model{
+		document: newDocument(width, height, pixel{R: '#'}, pixel{R: ' '}),
+		brushSize: 1,
+		historyDepth: defaultHistoryDepth,
+		rows: &rowCache{},
+		headless: true,
+	}
+}
+
+//
+// Run each of lines as if typed after a colon, failing t if any of them
+// is an error.
+//
+func run(t *testing.T, m model, lines ...string) model {
+	t.Helper()
+	for _, line := range lines {
+		var err error
+		if m, err = m.apply(interpretCmd(m, line)()); err != nil {
+			t.Fatalf(":%s: %v", line, err)
+		}
+	}
+	return m
+}
+
+//
+// Feed each of msgs to m's Update in turn, ignoring the commands that come
+// back.
+//
+func update(m model, msgs ...tea.Msg) model {
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func TestCanvasSize(t *testing.T) {
+	tests := []struct {
+		arg string
+		width, height int
+		ok bool
+	}{
+		{"120 40", 120, 40, true},
+		{fmt.Sprintf("%d %d", maxCanvasWidth, maxCanvasHeight), maxCanvasWidth, maxCanvasHeight, true},
+		{"0 10", 0, 0, false},
+		{"10 -1", 0, 0, false},
+		{"10", 0, 0, false},
+		{"ten 10", 0, 0, false},
+		{"100000 100000", 0, 0, false},
+		{fmt.Sprintf("10 %d", maxCanvasHeight + 1), 0, 0, false},
+	}
+	for _, test := range tests {
+		width, height, err := canvasSize("resize", test.arg)
+		if (err == nil) != test.ok || width != test.width || height != test.height {
+			t.Errorf("%q: got %dx%d, %v", test.arg, width, height, err)
+		}
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	tests := []struct {
+		verb, want string
+	}{
+		{"save", "save"},
+		{"q", "q"},
+		{"sa", "save"},
+		{"savea", "saveas"},
+		{"tabc!", "tabclose!"},
+		{"tabc", "tabclose"},
+		{"reco", ""},
+		{"recor", "record"},
+		{"minim", "minimap"},
+		{"zzz", ""},
+		{"", ""},
+		{"re", ""},
+	}
+	for _, test := range tests {
+		got, err := commandName(test.verb)
+		if got != test.want || (err == nil) != (test.want != "") {
+			t.Errorf("%q: got %q, %v, want %q", test.verb, got, err, test.want)
+		}
+	}
+}
+
+//
+// Every verb typed on its own either does its thing or says how it's used,
+// rather than crashing on the missing argument.  The ones that go to the
+// system clipboard or the backup directory are left out.
+//
+func TestRunCommandWithoutArgument(t *testing.T) {
+	m := testModel(10, 5)
+	for verb, c := range commands {
+		if verb == "pour" || verb == "yank" || verb == "recover" {
+			continue
+		}
+		for _, line := range []string{verb, verb + "  "} {
+			msg := runCommand(m, line)
+			if c.bare == nil && c.usage != "" {
+				err, ok := msg.(errMsg)
+				if !ok || err.err.Error() != "usage: " + c.usage {
+					t.Errorf("%q: got %#v, want its usage", line, msg)
+				}
+			} else if msg == nil {
+				t.Errorf("%q: got no message", line)
+			}
+		}
+	}
+}
+
+//
+// What runCommand hands each part of a command to.
+//
+func TestRunCommand(t *testing.T) {
+	m := testModel(10, 5)
+	tests := []struct {
+		line string
+		want tea.Msg
+	}{
+		{"resize 20 10", resizeMsg{20, 10, anchors["topleft"]}},
+		{"resize   20 10  ", resizeMsg{20, 10, anchors["topleft"]}},
+		{"canvas 20 10 top", resizeMsg{20, 10, anchors["top"]}},
+		{"minimap", minimapToggledMsg{}},
+		{"poly", polyArmedMsg{false}},
+		{"poly closed", polyArmedMsg{true}},
+		{"new 3 2", newCanvasMsg{3, 2}},
+		{"quit now", quitMsg{}},
+		{"q!", quitMsg{true}},
+		{"resize", errMsg{errors.New("usage: :resize <width> <height>")}},
+		{"q! now", errMsg{errors.New("\"q!\" takes no argument")}},
+		{"minimap on", errMsg{errors.New("\"minimap\" takes no argument")}},
+		{"undo 3", errMsg{errors.New("\"undo\" takes no argument")}},
+		{"nosuch 1", errMsg{errors.New("unknown command \"nosuch\"")}},
+		{"res 20 10", resizeMsg{20, 10, anchors["topleft"]}},
+		{"sa", errMsg{errors.New("usage: :save <file>")}},
+		{"savea", errMsg{errors.New("usage: :saveas <file>")}},
+		{"tabc", tabCloseMsg{}},
+		{"tabc!", tabCloseMsg{true}},
+		{"fli", errMsg{errors.New("ambiguous command \"fli\": fliph, flipv")}},
+		{"!", errMsg{errors.New("unknown command \"!\"")}},
+	}
+	for _, test := range tests {
+		if got := runCommand(m, test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %#v, want %#v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		buffer string
+		want []string
+	}{
+		{"border", []string{"border"}},
+		{"border | grid 5 | save out.txt", []string{"border", "grid 5", "save out.txt"}},
+		{"border\ngrid 5\n", []string{"border", "grid 5"}},
+		{"brush \\| | line", []string{"brush |", "line"}},
+		{" | |border||", []string{"border"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := splitCommands(test.buffer); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.buffer, got, test.want)
+		}
+	}
+}
+
+//
+// Chained commands run in order, each seeing what the last did, and stop
+// at the first one that fails.
+//
+func TestCommandChain(t *testing.T) {
+	m := run(t, testModel(4, 2), "resize 6 3 | brush x | resize 7 3")
+	if m.width != 7 || m.height != 3 || m.brushPrimary.R != 'x' {
+		t.Errorf("got %dx%d and brush %c, want 7x3 and x", m.width, m.height, m.brushPrimary.R)
+	}
+
+	m, err := m.apply(interpretCmd(m, "brush y | resize 0 0 | brush z")())
+	if err == nil || err.Error() != "command 2 (resize 0 0) failed: bad resize command \"0 0\"" {
+		t.Errorf("got %v", err)
+	}
+	if m.brushPrimary.R != 'y' || m.width != 7 {
+		t.Errorf("got brush %c and width %d, want y and 7", m.brushPrimary.R, m.width)
+	}
+}
+
+func TestSetTrimSafe(t *testing.T) {
+	m := run(t, testModel(2, 2), "set trimsafe on")
+	if !m.trimSafe {
+		t.Errorf(":set trimsafe on left it off")
+	}
+	if m = run(t, m, "set trimsafe off"); m.trimSafe {
+		t.Errorf(":set trimsafe off left it on")
+	}
+	for _, line := range []string{"set trimsafe maybe", "set nosuch on"} {
+		if _, err := m.apply(interpretCmd(m, line)()); err == nil {
+			t.Errorf(":%s: got no error", line)
+		}
+	}
+}
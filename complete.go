@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// Every verb in commands, for completion, in order.  The one-letter
+// shorthands and the ! forms are left out: they're reached by typing
+// them, and the full verbs are what completing is for.
+//
+var commandVerbs = completionVerbs()
+
+func completionVerbs() []string {
+	var verbs []string
+	for verb := range commands {
+		if len(verb) > 1 && !strings.HasSuffix(verb, "!") {
+			verbs = append(verbs, verb)
+		}
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+//
+// Verbs whose argument is a path.
+//
+var fileVerbs = map[string]bool{
+	"s": true, "save": true, "w": true, "write": true, "saveas": true,
+	"l": true, "load": true, "import": true, "export": true, "stamp": true,
+	"tabnew": true, "open": true, "source": true,
+}
+
+//
+// The possible completions of the buffer.  Each candidate replaces
+// whatever follows head, e.g. for "load ar" head is "load " and the
+// candidates are files starting with "ar".
+//
+func completionCandidates(buffer string) (head string, candidates []string) {
+	split := strings.SplitN(buffer, " ", 2)
+	if len(split) == 1 {
+		for _, verb := range commandVerbs {
+			if strings.HasPrefix(verb, buffer) {
+				candidates = append(candidates, verb + " ")
+			}
+		}
+		return "", candidates
+	}
+
+	verb, arg := split[0], split[1]
+	if !fileVerbs[verb] {
+		return buffer, nil
+	}
+	dir, base := filepath.Split(arg)
+	listDir := dir
+	if listDir == "" {
+		listDir = "."
+	}
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return buffer, nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	return verb + " " + dir, candidates
+}
+
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+//
+// Extend the buffer as far as is unambiguous.
+//
+func complete(buffer string) string {
+	head, candidates := completionCandidates(buffer)
+	if len(candidates) == 0 {
+		return buffer
+	}
+	if completed := head + commonPrefix(candidates); len(completed) > len(buffer) {
+		return completed
+	}
+	return buffer
+}
+
+//
+// Handle Tab in command mode.  The first Tab completes as far as possible
+// and lists the candidates if there are several; further Tabs cycle
+// through them.
+//
+func (m *model) tabComplete() {
+	if len(m.completions) > 1 {
+		m.completionIndex = (m.completionIndex + 1) % len(m.completions)
+		m.commandBuffer = m.completionHead + m.completions[m.completionIndex]
+		return
+	}
+	head, candidates := completionCandidates(m.commandBuffer)
+	m.commandBuffer = complete(m.commandBuffer)
+	if len(candidates) > 1 {
+		m.completionHead, m.completions, m.completionIndex = head, candidates, -1
+	}
+}
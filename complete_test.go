@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestCommandVerbs(t *testing.T) {
+	if !sort.StringsAreSorted(commandVerbs) {
+		t.Errorf("not sorted: %q", commandVerbs)
+	}
+	for _, verb := range commandVerbs {
+		if _, ok := commands[verb]; !ok {
+			t.Errorf("%q isn't a command", verb)
+		}
+	}
+	for verb := range commands {
+		if len(verb) > 1 && verb[len(verb)-1] != '!' && !slices.Contains(commandVerbs, verb) {
+			t.Errorf("%q doesn't complete", verb)
+		}
+	}
+}
+
+func TestCompletionCandidates(t *testing.T) {
+	tests := []struct {
+		buffer, head string
+		candidates []string
+	}{
+		{"mi", "", []string{"minimap ", "mirror "}},
+		{"mks", "", []string{"mksession "}},
+		{"q", "", []string{"quit "}},
+		{"tabn", "", []string{"tabnew ", "tabnext "}},
+		{"zz", "", nil},
+		{"grid 5", "grid 5", nil},
+	}
+	for _, test := range tests {
+		head, candidates := completionCandidates(test.buffer)
+		if head != test.head || !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("%q: got %q, %q, want %q, %q", test.buffer, head, candidates, test.head, test.candidates)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// The user's preferences, from the config file.  Anything the file doesn't
+// mention keeps its built-in default.
+//
+type config struct {
+	width, height int
+	brushPrimary pixel
+	brushSecondary pixel
+	brushSize int
+	brushShape brushShape
+	gridSize int
+	gridRune rune
+	shadeRamp []rune
+
+	//
+	// Seconds between backups, or zero for none.
+	//
+	autosave int
+
+	//
+	// How many undo steps to keep.
+	//
+	historyDepth int
+
+	//
+	// Nil unless the config file has one, in which case it overrides the
+	// palette file.
+	//
+	palette [][]rune
+
+	//
+	// Replacement bindings for the actions in the [keys] section.
+	//
+	keys map[string][]string
+}
+
+func defaultConfig() config {
+	return config{
+		width: defaultWidth,
+		height: defaultHeight,
+		brushPrimary: pixel{R: '#'},
+		brushSecondary: pixel{R: ' '},
+		brushSize: 1,
+		gridRune: defaultGridRune,
+		shadeRamp: defaultShadeRamp,
+		historyDepth: defaultHistoryDepth,
+		keys: map[string][]string{},
+	}
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopnik", "config.toml"), nil
+}
+
+//
+// The user's config, or the defaults if there's no config file.  A config
+// file that can't be read is ignored entirely, rather than half applied.
+//
+func loadConfig() config {
+	path, err := configPath()
+	if err != nil {
+		return defaultConfig()
+	}
+	fin, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return defaultConfig()
+	} else if err != nil {
+		warningf("ignoring config: %v", err)
+		return defaultConfig()
+	}
+	defer fin.Close()
+
+	cfg := defaultConfig()
+	if err := readConfig(fin, &cfg); err != nil {
+		warningf("ignoring config %s: %v", path, err)
+		return defaultConfig()
+	}
+	return cfg
+}
+
+//
+// The config file is a small subset of TOML: [section] headers, and
+// key = value lines where a value is a quoted string, an integer or an
+// array of strings on a single line.  Lines starting with # are comments.
+//
+//	[canvas]
+//	width = 120
+//	height = 40
+//
+//	[brush]
+//	primary = "█"
+//	color = "red"
+//
+//	[editor]
+//	autosave = 60
+//
+//	[history]
+//	depth = 500
+//
+//	[keys]
+//	command = ";"
+//	undo = ["u", "ctrl+z"]
+//
+func readConfig(fin io.Reader, cfg *config) error {
+	section := ""
+	scanner := bufio.NewScanner(fin)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.TrimSpace(text[1:len(text)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", line)
+		}
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+		if err := cfg.set(key, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("line %d: %s: %w", line, key, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (cfg *config) set(key, value string) error {
+	var err error
+	switch key {
+	case "canvas.width":
+		if cfg.width, err = configInt(value); err == nil && (cfg.width < 1 || cfg.width > maxCanvasWidth) {
+			err = fmt.Errorf("expected 1-%d", maxCanvasWidth)
+		}
+	case "canvas.height":
+		if cfg.height, err = configInt(value); err == nil && (cfg.height < 1 || cfg.height > maxCanvasHeight) {
+			err = fmt.Errorf("expected 1-%d", maxCanvasHeight)
+		}
+	case "brush.primary":
+		cfg.brushPrimary.R, err = configRune(value)
+	case "brush.secondary":
+		cfg.brushSecondary.R, err = configRune(value)
+	case "brush.color":
+		var s string
+		if s, err = configString(value); err == nil {
+			cfg.brushPrimary.FG, err = canvas.ParseColor(s)
+		}
+	case "brush.size":
+		if cfg.brushSize, err = configInt(value); err == nil && (cfg.brushSize < 1 || cfg.brushSize > maxBrushSize) {
+			err = fmt.Errorf("expected 1-%d", maxBrushSize)
+		}
+	case "brush.shape":
+		var s string
+		if s, err = configString(value); err == nil {
+			cfg.brushShape, err = parseBrushShape(s)
+		}
+	case "brush.ramp":
+		var s string
+		if s, err = configString(value); err == nil && s == "" {
+			err = fmt.Errorf("empty ramp")
+		}
+		cfg.shadeRamp = []rune(s)
+	case "grid.size":
+		cfg.gridSize, err = configInt(value)
+	case "grid.char":
+		cfg.gridRune, err = configRune(value)
+	case "editor.autosave":
+		cfg.autosave, err = configInt(value)
+	case "history.depth":
+		if cfg.historyDepth, err = configInt(value); err == nil && cfg.historyDepth < 1 {
+			err = fmt.Errorf("expected at least 1")
+		}
+	case "palette.groups":
+		var groups []string
+		if groups, err = configStrings(value); err == nil {
+			cfg.palette = nil
+			for _, group := range groups {
+				if g := []rune(strings.Join(strings.Fields(group), "")); len(g) > 0 {
+					cfg.palette = append(cfg.palette, g)
+				}
+			}
+		}
+	default:
+		action, ok := strings.CutPrefix(key, "keys.")
+		if _, known := defaultBindings[action]; !ok || !known {
+			return fmt.Errorf("unknown setting")
+		}
+		//
+		// Either a single key or an array of them.
+		//
+		if strings.HasPrefix(value, "[") {
+			cfg.keys[action], err = configStrings(value)
+		} else {
+			var s string
+			if s, err = configString(value); err == nil {
+				cfg.keys[action] = []string{s}
+			}
+		}
+	}
+	return err
+}
+
+func configInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad number %s", value)
+	}
+	return n, nil
+}
+
+func configString(value string) (string, error) {
+	s, err := strconv.Unquote(value)
+	if err != nil {
+		return "", fmt.Errorf("bad string %s", value)
+	}
+	return s, nil
+}
+
+func configRune(value string) (rune, error) {
+	s, err := configString(value)
+	if err != nil {
+		return 0, err
+	}
+	if runes := []rune(s); len(runes) == 1 {
+		return runes[0], nil
+	}
+	return 0, fmt.Errorf("expected a single character, got %s", value)
+}
+
+func configStrings(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("expected an array of strings")
+	}
+	value = strings.TrimSpace(value[1:len(value)-1])
+	var out []string
+	for value != "" {
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad string in array")
+		}
+		s, _ := strconv.Unquote(quoted)
+		out = append(out, s)
+		value = strings.TrimSpace(value[len(quoted):])
+		value = strings.TrimSpace(strings.TrimPrefix(value, ","))
+	}
+	return out, nil
+}
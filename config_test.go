@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	cfg := defaultConfig()
+	err := readConfig(strings.NewReader(`
+# a comment
+[canvas]
+width = 120
+height = 40
+
+[brush]
+primary = "█"
+size = 3
+
+[history]
+depth = 500
+
+[keys]
+undo = ["u", "ctrl+z"]
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.width != 120 || cfg.height != 40 || cfg.brushPrimary.R != '█' || cfg.brushSize != 3 {
+		t.Errorf("got %dx%d, brush %c of %d", cfg.width, cfg.height, cfg.brushPrimary.R, cfg.brushSize)
+	}
+	if cfg.historyDepth != 500 {
+		t.Errorf("got history depth %d, want 500", cfg.historyDepth)
+	}
+	if got := strings.Join(cfg.keys["undo"], " "); got != "u ctrl+z" {
+		t.Errorf("got undo bound to %q", got)
+	}
+	if defaultConfig().historyDepth != defaultHistoryDepth {
+		t.Errorf("the default history depth isn't %d", defaultHistoryDepth)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	tests := []struct {
+		config, want string
+	}{
+		{"[history]\ndepth = 0\n", "line 2: history.depth: expected at least 1"},
+		{"[history]\ndepth = -5\n", "line 2: history.depth: bad number -5"},
+		{"[brush]\nsize = 99\n", "line 2: brush.size: expected 1-32"},
+		{"[canvas]\nwidth = 5000\n", "line 2: canvas.width: expected 1-4096"},
+		{"[canvas]\nheight = 0\n", "line 2: canvas.height: expected 1-4096"},
+		{"[canvas]\nwidth\n", "line 2: expected key = value"},
+		{"colour = \"red\"\n", "line 1: colour: unknown setting"},
+	}
+	for _, test := range tests {
+		cfg := defaultConfig()
+		if err := readConfig(strings.NewReader(test.config), &cfg); err == nil || err.Error() != test.want {
+			t.Errorf("%q: got %v, want %s", test.config, err, test.want)
+		}
+	}
+}
+
+//
+// Undo keeps only as many steps as the history depth allows.
+//
+func TestHistoryDepth(t *testing.T) {
+	m := testModel(3, 1)
+	m.historyDepth = 2
+	for i := 0; i < 5; i++ {
+		m.pushHistory()
+	}
+	if len(m.history) != 2 {
+		t.Errorf("kept %d undo steps, want 2", len(m.history))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func (m *model) moveCursor(dx, dy int) {
+	m.cursorVisible = true
+	m.cursorX = clamp(m.cursorX + dx, 0, m.width - 1)
+	m.cursorY = clamp(m.cursorY + dy, 0, m.height - 1)
+	m.scrollTo(m.cursorX, m.cursorY)
+}
+
+func clamp(v, lo, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
+
+//
+// Like Canvas.Dump, but with the cursor shown in reverse video.  That's the
+// text insertion point while typing text, the keyboard cursor otherwise.
+// The canvas is the part that's in view.  Rows other than the cursor's are
+// cached between frames.
+//
+func (m model) dumpCanvasWithCursor(view Canvas, fout io.Writer) error {
+	cursorX, cursorY, visible := m.cursorX, m.cursorY, m.cursorVisible
+	if m.tool == toolText && m.textActive {
+		cursorX, cursorY, visible = m.textX, m.textY, true
+	}
+	originX, originY := m.viewOrigin()
+	cursorX, cursorY = cursorX - originX, cursorY - originY
+	width, height := m.viewSize()
+	if !visible || cursorX < 0 || cursorX >= width {
+		cursorY = -1
+	}
+	for y := 0; y < height; y++ {
+		row := view.Cells()[y][:width]
+		if y != cursorY {
+			if _, err := io.WriteString(fout, m.rows.render(y, row)); err != nil {
+				return err
+			}
+		} else {
+			x0 := canvas.CellOwner(row, cursorX)
+			x1 := x0 + 1
+			if canvas.IsWide(row[x0].R) && x1 < len(row) && row[x1] == padding {
+				x1++
+			}
+			if err := dumpRow(row[:x0], fout); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(fout, "\x1b[7m"); err != nil {
+				return err
+			}
+			if err := dumpRow(row[x0:x1], fout); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(fout, "\x1b[27m"); err != nil {
+				return err
+			}
+			if err := dumpRow(row[x1:], fout); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(fout, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Glyphs for lines that follow their own direction, by the step from each
+// cell to the next: across, down, down and to the right (\), and down and to
+// the left (/).  Box-drawing brushes get box-drawing glyphs.
+//
+var (
+	asciiLineGlyphs = [4]rune{'-', '|', '\\', '/'}
+	boxLineGlyphs = [4]rune{'─', '│', '╲', '╱'}
+)
+
+func lineGlyph(glyphs [4]rune, dx, dy int) rune {
+	switch {
+	case dy == 0:
+		return glyphs[0]
+	case dx == 0:
+		return glyphs[1]
+	case dx == dy:
+		return glyphs[2]
+	}
+	return glyphs[3]
+}
+
+//
+// Draw a line like Canvas.Line, but with each cell's glyph chosen by the
+// line's direction there, so that diagonals look like diagonals rather than
+// staircases of the brush.  A line that's a single point is the brush.
+//
+func drawSmoothLine(c Canvas, x0, y0, x1, y1 int, brush pixel) {
+	glyphs := asciiLineGlyphs
+	if _, ok := boxMask(brush.R); ok || brush.R == boxLineGlyphs[2] || brush.R == boxLineGlyphs[3] {
+		glyphs = boxLineGlyphs
+	}
+	canvas.LinePoints(x0, y0, x1, y1, func(x, y, dx, dy int) {
+		if dx != 0 || dy != 0 {
+			c.Set(x, y, brush.WithRune(lineGlyph(glyphs, dx, dy)))
+		} else {
+			c.Set(x, y, brush)
+		}
+	})
+}
+
+//
+// The nearest of the horizontal, vertical and exactly diagonal deltas to
+// (dx, dy), for lines drawn at 45 degree steps.  A diagonal goes as far as
+// the average of the two, so it stays about as long as the drag.
+//
+func orthoDelta(dx, dy int) (int, int) {
+	ax, sx := dx, 1
+	if ax < 0 {
+		ax, sx = -ax, -1
+	}
+	ay, sy := dy, 1
+	if ay < 0 {
+		ay, sy = -ay, -1
+	}
+	//
+	// Halfway between two directions is 22.5 degrees either side of each.
+	//
+	tan := math.Tan(math.Pi / 8)
+	switch {
+	case float64(ay) <= float64(ax) * tan:
+		return dx, 0
+	case float64(ax) <= float64(ay) * tan:
+		return 0, dy
+	}
+	d := (ax + ay + 1) / 2
+	return d * sx, d * sy
+}
+
+type borderGlyphs struct {
+	topLeft, horizontal, topRight, vertical, bottomLeft, bottomRight rune
+}
+
+var borderStyles = map[string]borderGlyphs{
+	"single": {'┌', '─', '┐', '│', '└', '┘'},
+	"double": {'╔', '═', '╗', '║', '╚', '╝'},
+	"rounded": {'╭', '─', '╮', '│', '╰', '╯'},
+	"ascii": {'+', '-', '+', '|', '+', '+'},
+	"clear": {' ', ' ', ' ', ' ', ' ', ' '},
+}
+
+//
+// Overwrite the outermost cells of the canvas with a border.
+//
+func drawBorder(canvas Canvas, g borderGlyphs, fg color) {
+	width, height := canvas.Bounds()
+	if width == 0 || height == 0 {
+		return
+	}
+	for x := 0; x < width; x++ {
+		canvas.Set(x, 0, pixel{R: g.horizontal, FG: fg})
+		canvas.Set(x, height-1, pixel{R: g.horizontal, FG: fg})
+	}
+	for y := 0; y < height; y++ {
+		canvas.Set(0, y, pixel{R: g.vertical, FG: fg})
+		canvas.Set(width-1, y, pixel{R: g.vertical, FG: fg})
+	}
+	canvas.Set(0, 0, pixel{R: g.topLeft, FG: fg})
+	canvas.Set(width-1, 0, pixel{R: g.topRight, FG: fg})
+	canvas.Set(0, height-1, pixel{R: g.bottomLeft, FG: fg})
+	canvas.Set(width-1, height-1, pixel{R: g.bottomRight, FG: fg})
+}
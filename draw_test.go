@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func TestOrthoDelta(t *testing.T) {
+	tests := []struct {
+		dx, dy, wantX, wantY int
+	}{
+		{0, 0, 0, 0},
+		{5, 0, 5, 0},
+		{5, 2, 5, 0},
+		{-5, 2, -5, 0},
+		{2, 5, 0, 5},
+		{1, -7, 0, -7},
+		{4, 4, 4, 4},
+		{5, 3, 4, 4},
+		{-3, 5, -4, 4},
+		{-4, -3, -4, -4},
+		{4, -2, 3, -3},
+	}
+	for _, test := range tests {
+		if x, y := orthoDelta(test.dx, test.dy); x != test.wantX || y != test.wantY {
+			t.Errorf("orthoDelta(%d, %d) = %d, %d, want %d, %d", test.dx, test.dy, x, y, test.wantX, test.wantY)
+		}
+	}
+}
+
+func TestDrawSmoothLine(t *testing.T) {
+	tests := []struct {
+		name string
+		x0, y0, x1, y1 int
+		brush rune
+		want []string
+	}{
+		{"point", 1, 1, 1, 1, '#', []string{"   ", " # ", "   "}},
+		{"across", 0, 1, 2, 1, '#', []string{"   ", "---", "   "}},
+		{"down", 1, 2, 1, 0, '#', []string{" | ", " | ", " | "}},
+		{"backslash", 0, 0, 2, 2, '#', []string{"\\  ", " \\ ", "  \\"}},
+		{"slash", 2, 0, 0, 2, '#', []string{"  /", " / ", "/  "}},
+		{"box", 0, 0, 2, 2, '─', []string{"╲  ", " ╲ ", "  ╲"}},
+	}
+	for _, test := range tests {
+		c := canvas.New(3, 3)
+		drawSmoothLine(c, test.x0, test.y0, test.x1, test.y1, pixel{R: test.brush})
+		if got := regionRows(c.Cells()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Cell dimensions in SVG user units, roughly the proportions of a
+// monospace glyph at font-size 16.
+//
+const (
+	svgCellWidth = 10
+	svgCellHeight = 20
+)
+
+//
+// How to export to each file extension that :export knows.
+//
+var exporters = map[string]func(Canvas, io.Writer) error{
+	".svg": renderSVG,
+	".html": renderHTML,
+	".htm": renderHTML,
+	".ans": renderANSI,
+}
+
+//
+// Render every non-blank cell as its own <text> element on a monospace grid,
+// over a <rect> if it has a background color.  There's no blinking.
+//
+func renderSVG(c Canvas, out io.Writer) error {
+	w, h := c.Bounds()
+	_, err := fmt.Fprintf(
+		out,
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"16\">\n",
+		w * svgCellWidth, h * svgCellHeight,
+	)
+	if err != nil {
+		return err
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := c.At(x, y)
+			fg, bg := exportColors(p, "#000000", "#ffffff")
+			if bg != "" {
+				//
+				// Wide glyphs cover their padding cell too.
+				//
+				width := svgCellWidth
+				if x + 1 < w && canvas.IsWide(p.R) && c.At(x + 1, y) == padding {
+					width *= 2
+				}
+				_, err := fmt.Fprintf(
+					out,
+					"<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+					x * svgCellWidth, y * svgCellHeight, width, svgCellHeight, bg,
+				)
+				if err != nil {
+					return err
+				}
+			}
+			if p.R == ' ' || p == transparent || p == padding {
+				continue
+			}
+			var text strings.Builder
+			if err := xml.EscapeText(&text, []byte(string(p.R))); err != nil {
+				return err
+			}
+			attributes := ""
+			if fg != "" {
+				attributes = fmt.Sprintf(" fill=\"%s\"", fg)
+			}
+			if p.Attrs & attrBold != 0 {
+				attributes += " font-weight=\"bold\""
+			}
+			if p.Attrs & attrUnderline != 0 {
+				attributes += " text-decoration=\"underline\""
+			}
+			_, err := fmt.Fprintf(
+				out,
+				"<text x=\"%d\" y=\"%d\"%s>%s</text>\n",
+				x * svgCellWidth, (y + 1) * svgCellHeight - svgCellHeight / 4, attributes, text.String(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = io.WriteString(out, "</svg>\n")
+	return err
+}
+
+//
+// The colors a cell is drawn in outside the terminal, with reverse video
+// applied.  Empty strings are the defaults, unless reversed, in which case
+// they're the given defaults swapped.
+//
+func exportColors(p pixel, defaultFg, defaultBg string) (fg, bg string) {
+	fg, bg = p.FG.Hex(), p.BG.Hex()
+	if p.Attrs & attrReverse == 0 {
+		return fg, bg
+	}
+	if fg == "" {
+		fg = defaultFg
+	}
+	if bg == "" {
+		bg = defaultBg
+	}
+	return bg, fg
+}
+
+//
+// Render the canvas as a frame of terminal output: the cursor goes home,
+// the rows are drawn the way Canvas.Dump draws them, and everything is
+// reset at the end so that nothing bleeds into what comes next.
+//
+func renderANSI(c Canvas, out io.Writer) error {
+	if _, err := io.WriteString(out, "\x1b[H"); err != nil {
+		return err
+	}
+	if err := c.Dump(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\x1b[0m")
+	return err
+}
+
+//
+// The CSS for a cell's look, or the empty string if it's plain.  Reverse
+// video uses the page's own colors where the cell doesn't have any.
+//
+func cssStyle(p pixel) string {
+	var rules []string
+	fg, bg := exportColors(p, "canvastext", "canvas")
+	if fg != "" {
+		rules = append(rules, "color: " + fg)
+	}
+	if bg != "" {
+		rules = append(rules, "background-color: " + bg)
+	}
+	if p.Attrs & attrBold != 0 {
+		rules = append(rules, "font-weight: bold")
+	}
+	var decorations []string
+	if p.Attrs & attrUnderline != 0 {
+		decorations = append(decorations, "underline")
+	}
+	if p.Attrs & attrBlink != 0 {
+		decorations = append(decorations, "blink")
+	}
+	if decorations != nil {
+		rules = append(rules, "text-decoration: " + strings.Join(decorations, " "))
+	}
+	return strings.Join(rules, "; ")
+}
+
+//
+// Render the canvas as a standalone HTML page.  Runs of same-colored cells
+// share a single <span>, and uncolored cells aren't wrapped at all.
+//
+func renderHTML(c Canvas, out io.Writer) error {
+	w, h := c.Bounds()
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n</head>\n<body>\n<pre>\n")
+	for y := 0; y < h; y++ {
+		style := ""
+		for x := 0; x < w; x++ {
+			p := c.At(x, y)
+			if p == padding {
+				continue
+			}
+			if s := cssStyle(p); s != style {
+				if style != "" {
+					b.WriteString("</span>")
+				}
+				if s != "" {
+					fmt.Fprintf(&b, "<span style=\"%s\">", s)
+				}
+				style = s
+			}
+			if p == transparent {
+				p.R = ' '
+			}
+			b.WriteString(html.EscapeString(string(p.R)))
+		}
+		if style != "" {
+			b.WriteString("</span>")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("</pre>\n</body>\n</html>\n")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
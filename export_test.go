@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+//
+// A canvas with one of everything the exporters treat specially: markup
+// characters, a background color, a foreground color with attributes, a
+// wide glyph and blanks.
+//
+func exportCanvas() Canvas {
+	c := canvasOf(
+		"<& ",
+		"a世",
+	)
+	c.Set(2, 0, pixel{R: ' ', BG: "1"})
+	c.Set(0, 1, pixel{R: 'a', FG: "9", Attrs: attrBold | attrUnderline})
+	return c
+}
+
+func TestRenderSVG(t *testing.T) {
+	var b strings.Builder
+	if err := renderSVG(exportCanvas(), &b); err != nil {
+		t.Fatal(err)
+	}
+	svg := b.String()
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("not well-formed: %v\n%s", err, svg)
+		}
+	}
+	for _, want := range []string{
+		`width="30" height="40"`,
+		`<text x="0" y="15">&lt;</text>`,
+		`<text x="10" y="15">&amp;</text>`,
+		`<rect x="20" y="0" width="10" height="20" fill="#cd0000"/>`,
+		`<text x="0" y="35" fill="#ff0000" font-weight="bold" text-decoration="underline">a</text>`,
+		`<text x="10" y="35">世</text>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("missing %s in\n%s", want, svg)
+		}
+	}
+	if n := strings.Count(svg, "<text"); n != 4 {
+		t.Errorf("got %d <text> elements, want 4, one for each glyph", n)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	var b strings.Builder
+	if err := renderHTML(exportCanvas(), &b); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	want := "<pre>\n" +
+		"&lt;&amp;<span style=\"background-color: #cd0000\"> </span>\n" +
+		"<span style=\"color: #ff0000; font-weight: bold; text-decoration: underline\">a</span>世\n" +
+		"</pre>\n"
+	if !strings.HasPrefix(page, "<!DOCTYPE html>\n") || !strings.Contains(page, want) {
+		t.Errorf("got\n%s\nwant it to hold\n%s", page, want)
+	}
+
+	b.Reset()
+	if err := renderHTML(canvasOf("a.b"), &b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<pre>\na b\n</pre>") {
+		t.Errorf("transparent cells aren't blanks:\n%s", b.String())
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	var b strings.Builder
+	if err := renderANSI(exportCanvas(), &b); err != nil {
+		t.Fatal(err)
+	}
+	frame := b.String()
+	if !strings.HasPrefix(frame, "\x1b[H") {
+		t.Errorf("doesn't start by moving the cursor home: %q", frame)
+	}
+	if !strings.HasSuffix(frame, "\x1b[0m") {
+		t.Errorf("doesn't end with a reset: %q", frame)
+	}
+	want := "\x1b[H<&\x1b[48;5;1m \x1b[0m\n\x1b[38;5;9;1;4ma\x1b[39;22;24m世\n\x1b[0m"
+	if frame != want {
+		t.Errorf("got %q, want %q", frame, want)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//
+// The Unicode names of the glyphs most used for drawing: box drawing, block
+// elements, a few geometric shapes and the arrows.
+//
+var glyphNames = map[string]rune{
+	"MIDDLE DOT": '·',
+	"BULLET": '•',
+	"LEFTWARDS ARROW": '←',
+	"UPWARDS ARROW": '↑',
+	"RIGHTWARDS ARROW": '→',
+	"DOWNWARDS ARROW": '↓',
+	"LEFT RIGHT ARROW": '↔',
+	"UP DOWN ARROW": '↕',
+	"NORTH WEST ARROW": '↖',
+	"NORTH EAST ARROW": '↗',
+	"SOUTH EAST ARROW": '↘',
+	"SOUTH WEST ARROW": '↙',
+	"BULLET OPERATOR": '∙',
+	"BOX DRAWINGS LIGHT HORIZONTAL": '─',
+	"BOX DRAWINGS HEAVY HORIZONTAL": '━',
+	"BOX DRAWINGS LIGHT VERTICAL": '│',
+	"BOX DRAWINGS HEAVY VERTICAL": '┃',
+	"BOX DRAWINGS LIGHT TRIPLE DASH HORIZONTAL": '┄',
+	"BOX DRAWINGS HEAVY TRIPLE DASH HORIZONTAL": '┅',
+	"BOX DRAWINGS LIGHT TRIPLE DASH VERTICAL": '┆',
+	"BOX DRAWINGS HEAVY TRIPLE DASH VERTICAL": '┇',
+	"BOX DRAWINGS LIGHT QUADRUPLE DASH HORIZONTAL": '┈',
+	"BOX DRAWINGS HEAVY QUADRUPLE DASH HORIZONTAL": '┉',
+	"BOX DRAWINGS LIGHT QUADRUPLE DASH VERTICAL": '┊',
+	"BOX DRAWINGS HEAVY QUADRUPLE DASH VERTICAL": '┋',
+	"BOX DRAWINGS LIGHT DOWN AND RIGHT": '┌',
+	"BOX DRAWINGS DOWN LIGHT AND RIGHT HEAVY": '┍',
+	"BOX DRAWINGS DOWN HEAVY AND RIGHT LIGHT": '┎',
+	"BOX DRAWINGS HEAVY DOWN AND RIGHT": '┏',
+	"BOX DRAWINGS LIGHT DOWN AND LEFT": '┐',
+	"BOX DRAWINGS DOWN LIGHT AND LEFT HEAVY": '┑',
+	"BOX DRAWINGS DOWN HEAVY AND LEFT LIGHT": '┒',
+	"BOX DRAWINGS HEAVY DOWN AND LEFT": '┓',
+	"BOX DRAWINGS LIGHT UP AND RIGHT": '└',
+	"BOX DRAWINGS UP LIGHT AND RIGHT HEAVY": '┕',
+	"BOX DRAWINGS UP HEAVY AND RIGHT LIGHT": '┖',
+	"BOX DRAWINGS HEAVY UP AND RIGHT": '┗',
+	"BOX DRAWINGS LIGHT UP AND LEFT": '┘',
+	"BOX DRAWINGS UP LIGHT AND LEFT HEAVY": '┙',
+	"BOX DRAWINGS UP HEAVY AND LEFT LIGHT": '┚',
+	"BOX DRAWINGS HEAVY UP AND LEFT": '┛',
+	"BOX DRAWINGS LIGHT VERTICAL AND RIGHT": '├',
+	"BOX DRAWINGS VERTICAL LIGHT AND RIGHT HEAVY": '┝',
+	"BOX DRAWINGS UP HEAVY AND RIGHT DOWN LIGHT": '┞',
+	"BOX DRAWINGS DOWN HEAVY AND RIGHT UP LIGHT": '┟',
+	"BOX DRAWINGS VERTICAL HEAVY AND RIGHT LIGHT": '┠',
+	"BOX DRAWINGS DOWN LIGHT AND RIGHT UP HEAVY": '┡',
+	"BOX DRAWINGS UP LIGHT AND RIGHT DOWN HEAVY": '┢',
+	"BOX DRAWINGS HEAVY VERTICAL AND RIGHT": '┣',
+	"BOX DRAWINGS LIGHT VERTICAL AND LEFT": '┤',
+	"BOX DRAWINGS VERTICAL LIGHT AND LEFT HEAVY": '┥',
+	"BOX DRAWINGS UP HEAVY AND LEFT DOWN LIGHT": '┦',
+	"BOX DRAWINGS DOWN HEAVY AND LEFT UP LIGHT": '┧',
+	"BOX DRAWINGS VERTICAL HEAVY AND LEFT LIGHT": '┨',
+	"BOX DRAWINGS DOWN LIGHT AND LEFT UP HEAVY": '┩',
+	"BOX DRAWINGS UP LIGHT AND LEFT DOWN HEAVY": '┪',
+	"BOX DRAWINGS HEAVY VERTICAL AND LEFT": '┫',
+	"BOX DRAWINGS LIGHT DOWN AND HORIZONTAL": '┬',
+	"BOX DRAWINGS LEFT HEAVY AND RIGHT DOWN LIGHT": '┭',
+	"BOX DRAWINGS RIGHT HEAVY AND LEFT DOWN LIGHT": '┮',
+	"BOX DRAWINGS DOWN LIGHT AND HORIZONTAL HEAVY": '┯',
+	"BOX DRAWINGS DOWN HEAVY AND HORIZONTAL LIGHT": '┰',
+	"BOX DRAWINGS RIGHT LIGHT AND LEFT DOWN HEAVY": '┱',
+	"BOX DRAWINGS LEFT LIGHT AND RIGHT DOWN HEAVY": '┲',
+	"BOX DRAWINGS HEAVY DOWN AND HORIZONTAL": '┳',
+	"BOX DRAWINGS LIGHT UP AND HORIZONTAL": '┴',
+	"BOX DRAWINGS LEFT HEAVY AND RIGHT UP LIGHT": '┵',
+	"BOX DRAWINGS RIGHT HEAVY AND LEFT UP LIGHT": '┶',
+	"BOX DRAWINGS UP LIGHT AND HORIZONTAL HEAVY": '┷',
+	"BOX DRAWINGS UP HEAVY AND HORIZONTAL LIGHT": '┸',
+	"BOX DRAWINGS RIGHT LIGHT AND LEFT UP HEAVY": '┹',
+	"BOX DRAWINGS LEFT LIGHT AND RIGHT UP HEAVY": '┺',
+	"BOX DRAWINGS HEAVY UP AND HORIZONTAL": '┻',
+	"BOX DRAWINGS LIGHT VERTICAL AND HORIZONTAL": '┼',
+	"BOX DRAWINGS LEFT HEAVY AND RIGHT VERTICAL LIGHT": '┽',
+	"BOX DRAWINGS RIGHT HEAVY AND LEFT VERTICAL LIGHT": '┾',
+	"BOX DRAWINGS VERTICAL LIGHT AND HORIZONTAL HEAVY": '┿',
+	"BOX DRAWINGS UP HEAVY AND DOWN HORIZONTAL LIGHT": '╀',
+	"BOX DRAWINGS DOWN HEAVY AND UP HORIZONTAL LIGHT": '╁',
+	"BOX DRAWINGS VERTICAL HEAVY AND HORIZONTAL LIGHT": '╂',
+	"BOX DRAWINGS LEFT UP HEAVY AND RIGHT DOWN LIGHT": '╃',
+	"BOX DRAWINGS RIGHT UP HEAVY AND LEFT DOWN LIGHT": '╄',
+	"BOX DRAWINGS LEFT DOWN HEAVY AND RIGHT UP LIGHT": '╅',
+	"BOX DRAWINGS RIGHT DOWN HEAVY AND LEFT UP LIGHT": '╆',
+	"BOX DRAWINGS DOWN LIGHT AND UP HORIZONTAL HEAVY": '╇',
+	"BOX DRAWINGS UP LIGHT AND DOWN HORIZONTAL HEAVY": '╈',
+	"BOX DRAWINGS RIGHT LIGHT AND LEFT VERTICAL HEAVY": '╉',
+	"BOX DRAWINGS LEFT LIGHT AND RIGHT VERTICAL HEAVY": '╊',
+	"BOX DRAWINGS HEAVY VERTICAL AND HORIZONTAL": '╋',
+	"BOX DRAWINGS LIGHT DOUBLE DASH HORIZONTAL": '╌',
+	"BOX DRAWINGS HEAVY DOUBLE DASH HORIZONTAL": '╍',
+	"BOX DRAWINGS LIGHT DOUBLE DASH VERTICAL": '╎',
+	"BOX DRAWINGS HEAVY DOUBLE DASH VERTICAL": '╏',
+	"BOX DRAWINGS DOUBLE HORIZONTAL": '═',
+	"BOX DRAWINGS DOUBLE VERTICAL": '║',
+	"BOX DRAWINGS DOWN SINGLE AND RIGHT DOUBLE": '╒',
+	"BOX DRAWINGS DOWN DOUBLE AND RIGHT SINGLE": '╓',
+	"BOX DRAWINGS DOUBLE DOWN AND RIGHT": '╔',
+	"BOX DRAWINGS DOWN SINGLE AND LEFT DOUBLE": '╕',
+	"BOX DRAWINGS DOWN DOUBLE AND LEFT SINGLE": '╖',
+	"BOX DRAWINGS DOUBLE DOWN AND LEFT": '╗',
+	"BOX DRAWINGS UP SINGLE AND RIGHT DOUBLE": '╘',
+	"BOX DRAWINGS UP DOUBLE AND RIGHT SINGLE": '╙',
+	"BOX DRAWINGS DOUBLE UP AND RIGHT": '╚',
+	"BOX DRAWINGS UP SINGLE AND LEFT DOUBLE": '╛',
+	"BOX DRAWINGS UP DOUBLE AND LEFT SINGLE": '╜',
+	"BOX DRAWINGS DOUBLE UP AND LEFT": '╝',
+	"BOX DRAWINGS VERTICAL SINGLE AND RIGHT DOUBLE": '╞',
+	"BOX DRAWINGS VERTICAL DOUBLE AND RIGHT SINGLE": '╟',
+	"BOX DRAWINGS DOUBLE VERTICAL AND RIGHT": '╠',
+	"BOX DRAWINGS VERTICAL SINGLE AND LEFT DOUBLE": '╡',
+	"BOX DRAWINGS VERTICAL DOUBLE AND LEFT SINGLE": '╢',
+	"BOX DRAWINGS DOUBLE VERTICAL AND LEFT": '╣',
+	"BOX DRAWINGS DOWN SINGLE AND HORIZONTAL DOUBLE": '╤',
+	"BOX DRAWINGS DOWN DOUBLE AND HORIZONTAL SINGLE": '╥',
+	"BOX DRAWINGS DOUBLE DOWN AND HORIZONTAL": '╦',
+	"BOX DRAWINGS UP SINGLE AND HORIZONTAL DOUBLE": '╧',
+	"BOX DRAWINGS UP DOUBLE AND HORIZONTAL SINGLE": '╨',
+	"BOX DRAWINGS DOUBLE UP AND HORIZONTAL": '╩',
+	"BOX DRAWINGS VERTICAL SINGLE AND HORIZONTAL DOUBLE": '╪',
+	"BOX DRAWINGS VERTICAL DOUBLE AND HORIZONTAL SINGLE": '╫',
+	"BOX DRAWINGS DOUBLE VERTICAL AND HORIZONTAL": '╬',
+	"BOX DRAWINGS LIGHT ARC DOWN AND RIGHT": '╭',
+	"BOX DRAWINGS LIGHT ARC DOWN AND LEFT": '╮',
+	"BOX DRAWINGS LIGHT ARC UP AND LEFT": '╯',
+	"BOX DRAWINGS LIGHT ARC UP AND RIGHT": '╰',
+	"BOX DRAWINGS LIGHT DIAGONAL UPPER RIGHT TO LOWER LEFT": '╱',
+	"BOX DRAWINGS LIGHT DIAGONAL UPPER LEFT TO LOWER RIGHT": '╲',
+	"BOX DRAWINGS LIGHT DIAGONAL CROSS": '╳',
+	"BOX DRAWINGS LIGHT LEFT": '╴',
+	"BOX DRAWINGS LIGHT UP": '╵',
+	"BOX DRAWINGS LIGHT RIGHT": '╶',
+	"BOX DRAWINGS LIGHT DOWN": '╷',
+	"BOX DRAWINGS HEAVY LEFT": '╸',
+	"BOX DRAWINGS HEAVY UP": '╹',
+	"BOX DRAWINGS HEAVY RIGHT": '╺',
+	"BOX DRAWINGS HEAVY DOWN": '╻',
+	"BOX DRAWINGS LIGHT LEFT AND HEAVY RIGHT": '╼',
+	"BOX DRAWINGS LIGHT UP AND HEAVY DOWN": '╽',
+	"BOX DRAWINGS HEAVY LEFT AND LIGHT RIGHT": '╾',
+	"BOX DRAWINGS HEAVY UP AND LIGHT DOWN": '╿',
+	"UPPER HALF BLOCK": '▀',
+	"LOWER ONE EIGHTH BLOCK": '▁',
+	"LOWER ONE QUARTER BLOCK": '▂',
+	"LOWER THREE EIGHTHS BLOCK": '▃',
+	"LOWER HALF BLOCK": '▄',
+	"LOWER FIVE EIGHTHS BLOCK": '▅',
+	"LOWER THREE QUARTERS BLOCK": '▆',
+	"LOWER SEVEN EIGHTHS BLOCK": '▇',
+	"FULL BLOCK": '█',
+	"LEFT SEVEN EIGHTHS BLOCK": '▉',
+	"LEFT THREE QUARTERS BLOCK": '▊',
+	"LEFT FIVE EIGHTHS BLOCK": '▋',
+	"LEFT HALF BLOCK": '▌',
+	"LEFT THREE EIGHTHS BLOCK": '▍',
+	"LEFT ONE QUARTER BLOCK": '▎',
+	"LEFT ONE EIGHTH BLOCK": '▏',
+	"RIGHT HALF BLOCK": '▐',
+	"LIGHT SHADE": '░',
+	"MEDIUM SHADE": '▒',
+	"DARK SHADE": '▓',
+	"UPPER ONE EIGHTH BLOCK": '▔',
+	"RIGHT ONE EIGHTH BLOCK": '▕',
+	"QUADRANT LOWER LEFT": '▖',
+	"QUADRANT LOWER RIGHT": '▗',
+	"QUADRANT UPPER LEFT": '▘',
+	"QUADRANT UPPER LEFT AND LOWER LEFT AND LOWER RIGHT": '▙',
+	"QUADRANT UPPER LEFT AND LOWER RIGHT": '▚',
+	"QUADRANT UPPER LEFT AND UPPER RIGHT AND LOWER LEFT": '▛',
+	"QUADRANT UPPER LEFT AND UPPER RIGHT AND LOWER RIGHT": '▜',
+	"QUADRANT UPPER RIGHT": '▝',
+	"QUADRANT UPPER RIGHT AND LOWER LEFT": '▞',
+	"QUADRANT UPPER RIGHT AND LOWER LEFT AND LOWER RIGHT": '▟',
+	"BLACK SQUARE": '■',
+	"WHITE SQUARE": '□',
+	"BLACK SMALL SQUARE": '▪',
+	"WHITE SMALL SQUARE": '▫',
+	"BLACK UP-POINTING TRIANGLE": '▲',
+	"BLACK RIGHT-POINTING TRIANGLE": '▶',
+	"BLACK RIGHT-POINTING POINTER": '►',
+	"BLACK DOWN-POINTING TRIANGLE": '▼',
+	"BLACK LEFT-POINTING TRIANGLE": '◀',
+	"BLACK LEFT-POINTING POINTER": '◄',
+	"BLACK DIAMOND": '◆',
+	"WHITE DIAMOND": '◇',
+	"WHITE CIRCLE": '○',
+	"BLACK CIRCLE": '●',
+	"BLACK LOWER RIGHT TRIANGLE": '◢',
+	"BLACK LOWER LEFT TRIANGLE": '◣',
+	"BLACK UPPER LEFT TRIANGLE": '◤',
+	"BLACK UPPER RIGHT TRIANGLE": '◥',
+}
+
+//
+// Look up a glyph by name, ignoring case and accepting - or _ for spaces,
+// so "full block", "name:full-block" and "FULL_BLOCK" are all the same.
+// The "BOX DRAWINGS" that starts so many of the names can be left off.
+//
+func lookupGlyph(typed string) (rune, error) {
+	name := strings.TrimPrefix(typed, "name:")
+	name = strings.Join(strings.Fields(strings.ToUpper(strings.NewReplacer("-", " ", "_", " ").Replace(name))), " ")
+	if r, ok := glyphNames[name]; ok {
+		return r, nil
+	} else if r, ok := glyphNames["BOX DRAWINGS " + name]; ok {
+		return r, nil
+	}
+	if matches := closeGlyphNames(name, 3); len(matches) > 0 {
+		return 0, fmt.Errorf("unknown character %q, did you mean %s?", typed, strings.Join(matches, ", "))
+	}
+	return 0, fmt.Errorf("unknown character %q", typed)
+}
+
+//
+// Up to n names sharing the most words with name, shortest first among
+// equals.  Words that start the same count as shared, to allow for typos.
+//
+func closeGlyphNames(name string, n int) []string {
+	words := strings.Fields(name)
+	shared := map[string]int{}
+	for candidate := range glyphNames {
+		for _, word := range words {
+			if word == "BOX" || word == "DRAWINGS" {
+				continue
+			}
+			for _, other := range strings.Fields(candidate) {
+				if word == other || (len(word) >= 4 && len(other) >= 4 && word[:4] == other[:4]) {
+					shared[candidate]++
+					break
+				}
+			}
+		}
+	}
+	var matches []string
+	for candidate := range shared {
+		matches = append(matches, candidate)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if shared[a] != shared[b] {
+			return shared[a] > shared[b]
+		} else if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	for i := range matches {
+		matches[i] = strings.ToLower(matches[i])
+	}
+	return matches
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+var defaultRamp = []rune(" ░▒▓█")
+
+type gradientDirection int
+
+const (
+	gradientHorizontal gradientDirection = iota
+	gradientVertical
+	gradientDiagonal
+)
+
+func parseGradientDirection(s string) (gradientDirection, error) {
+	switch s {
+	case "h", "horizontal":
+		return gradientHorizontal, nil
+	case "v", "vertical":
+		return gradientVertical, nil
+	case "d", "diagonal":
+		return gradientDiagonal, nil
+	}
+	return gradientHorizontal, fmt.Errorf("bad gradient direction %q: expected horizontal, vertical or diagonal", s)
+}
+
+//
+// The index into a ramp of n glyphs for a position t between 0 and 1,
+// rounding to the nearest glyph.
+//
+func rampIndex(t float64, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	t = math.Max(0, math.Min(1, t))
+	return int(math.Round(t * float64(n - 1)))
+}
+
+//
+// How far along a rectangle from (x0, y0) to (x1, y1) the cell (x, y) is,
+// between 0 and 1.  The ramp runs from the corner that was dragged from,
+// so dragging the other way reverses it.
+//
+func gradientPosition(s selection, x, y int, dir gradientDirection) float64 {
+	along := func(v, from, to int) float64 {
+		if from == to {
+			return 0
+		}
+		return float64(v - from) / float64(to - from)
+	}
+	switch dir {
+	case gradientVertical:
+		return along(y, s.y0, s.y1)
+	case gradientDiagonal:
+		return (along(x, s.x0, s.x1) + along(y, s.y0, s.y1)) / 2
+	}
+	return along(x, s.x0, s.x1)
+}
+
+func drawGradient(canvas Canvas, s selection, ramp []rune, dir gradientDirection, fg color) {
+	if len(ramp) == 0 {
+		return
+	}
+	n := s.normalized()
+	for y := n.y0; y <= n.y1; y++ {
+		for x := n.x0; x <= n.x1; x++ {
+			r := ramp[rampIndex(gradientPosition(s, x, y, dir), len(ramp))]
+			canvas.Set(x, y, pixel{R: r, FG: fg})
+		}
+	}
+}
+
+//
+// Where ink of the given opacity leaves a cell of a ramp of n glyphs, from
+// blank at 0 to solid at n - 1, when it's painted at brush over existing.
+// Like layers of ink, the two darken each other, so the cell never gets
+// lighter, and gets at least a step darker if the brush is darker.
+//
+func blendShade(existing, brush, n int, opacity float64) int {
+	if n < 2 {
+		return existing
+	}
+	darkness := func(i int) float64 {
+		return float64(i) / float64(n - 1)
+	}
+	i := rampIndex(1 - (1 - darkness(existing)) * (1 - darkness(brush) * opacity), n)
+	if brush > existing && i <= existing {
+		i = existing + 1
+	}
+	return clamp(i, existing, n - 1)
+}
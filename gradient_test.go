@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRampIndex(t *testing.T) {
+	tests := []struct {
+		t float64
+		n, want int
+	}{
+		{0, 5, 0},
+		{1, 5, 4},
+		{0.5, 5, 2},
+		{0.3, 5, 1},
+		{0.4, 5, 2},
+		{-1, 5, 0},
+		{2, 5, 4},
+		{0.7, 1, 0},
+		{0.5, 0, 0},
+	}
+	for _, test := range tests {
+		if got := rampIndex(test.t, test.n); got != test.want {
+			t.Errorf("rampIndex(%v, %d) = %d, want %d", test.t, test.n, got, test.want)
+		}
+	}
+}
+
+func TestDrawGradient(t *testing.T) {
+	tests := []struct {
+		name string
+		s selection
+		dir gradientDirection
+		want []string
+	}{
+		{"horizontal", selection{0, 0, 4, 0}, gradientHorizontal, []string{"abcde", "....."}},
+		{"dragged right to left", selection{4, 0, 0, 0}, gradientHorizontal, []string{"edcba", "....."}},
+		{"vertical", selection{0, 0, 1, 1}, gradientVertical, []string{"aa...", "ee..."}},
+		{"diagonal", selection{0, 0, 2, 1}, gradientDiagonal, []string{"abc..", "cde.."}},
+	}
+	for _, test := range tests {
+		c := canvasOf(".....", ".....")
+		drawGradient(c, test.s, []rune("abcde"), test.dir, noColor)
+		if got := regionRows(c.Cells()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBlendShade(t *testing.T) {
+	tests := []struct {
+		existing, brush int
+		opacity float64
+		want int
+	}{
+		{0, 4, 1, 4},
+		{0, 2, 1, 2},
+		{0, 4, 0.5, 2},
+		{0, 4, 0.25, 1},
+		{1, 1, 1, 2},
+		{1, 4, 0.5, 3},
+		{2, 1, 0.25, 2},
+		{3, 0, 1, 3},
+		{4, 4, 0.5, 4},
+	}
+	for _, test := range tests {
+		if got := blendShade(test.existing, test.brush, 5, test.opacity); got != test.want {
+			t.Errorf("blendShade(%d, %d, 5, %v) = %d, want %d", test.existing, test.brush, test.opacity, got, test.want)
+		}
+	}
+
+	//
+	// Ink only ever darkens, at least a step when the brush is darker, and
+	// never goes off the ramp.
+	//
+	for _, opacity := range []float64{0.1, 0.25, 0.5, 0.75, 1} {
+		for existing := 0; existing < 5; existing++ {
+			for brush := 0; brush < 5; brush++ {
+				got := blendShade(existing, brush, 5, opacity)
+				if got < existing || got > 4 || (brush > existing && got == existing) {
+					t.Errorf("blendShade(%d, %d, 5, %v) = %d", existing, brush, opacity, got)
+				}
+			}
+		}
+	}
+	if got := blendShade(3, 1, 1, 0.5); got != 3 {
+		t.Errorf("a ramp of one glyph: got %d", got)
+	}
+}
+
+func TestInked(t *testing.T) {
+	m := testModel(3, 1)
+	m.layers[0].Grid = canvasOf("x░▓")
+	brush := pixel{R: '▓', FG: "2"}
+	if got := m.inked(0, 0, brush); got != brush {
+		t.Errorf("full ink: got %v", got)
+	}
+	m.ink = 0.5
+	for x, want := range []rune{'▒', '▒', '▓'} {
+		if got := m.inked(x, 0, brush); got != brush.WithRune(want) {
+			t.Errorf("over %c: got %c, want %c", m.canvas().At(x, 0).R, got.R, want)
+		}
+	}
+	if other := (pixel{R: '#'}); m.inked(0, 0, other) != other {
+		t.Errorf("a brush that isn't a shade got blended")
+	}
+}
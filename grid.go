@@ -0,0 +1,54 @@
+package main
+
+import "math"
+
+const defaultGridRune = '·'
+
+//
+// Gridlines are drawn in dark gray, and only over blank cells so that they
+// never hide anything.
+//
+func drawGrid(canvas Canvas, size int, r rune) {
+	if size <= 0 {
+		return
+	}
+	for y, row := range canvas.Cells() {
+		for x := range row {
+			if (x % size == 0 || y % size == 0) && row[x].IsBlank() && row[x] != padding {
+				row[x] = pixel{R: r, FG: "8"}
+			}
+		}
+	}
+}
+
+//
+// The gridline intersection nearest to (x, y) for gridlines every size
+// cells, or (x, y) itself if there's no grid.  Halfway rounds up.
+//
+func snapPoint(x, y, size int) (int, int) {
+	if size <= 0 {
+		return x, y
+	}
+	nearest := func(v int) int {
+		return int(math.Floor(float64(v) / float64(size) + 0.5)) * size
+	}
+	return nearest(x), nearest(y)
+}
+
+//
+// Where a shape's end at (x, y) goes: the nearest intersection on the
+// canvas when snapping, (x, y) otherwise.
+//
+func (m model) snapped(x, y int) (int, int) {
+	if !m.snap || !m.tool.twoClick() {
+		return x, y
+	}
+	x, y = snapPoint(x, y, m.gridSize)
+	for x >= m.width && m.gridSize > 0 {
+		x -= m.gridSize
+	}
+	for y >= m.height && m.gridSize > 0 {
+		y -= m.gridSize
+	}
+	return x, y
+}
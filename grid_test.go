@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrawGrid(t *testing.T) {
+	c := canvasOf("a    ", "  世 ", "     ")
+	c.Set(2, 0, pixel{R: ' ', BG: "4"})
+	drawGrid(c, 2, '+')
+	want := []string{"a+ ++", "+ 世+", "+++++"}
+	if got := regionRows(c.Cells()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if c.At(2, 0) != (pixel{R: ' ', BG: "4"}) || c.At(3, 1) != padding {
+		t.Errorf("drew over a background color or a wide glyph: %v, %v", c.At(2, 0), c.At(3, 1))
+	}
+}
+
+func TestSnapPoint(t *testing.T) {
+	tests := []struct {
+		x, y, size, wantX, wantY int
+	}{
+		{7, 3, 0, 7, 3},
+		{7, 3, -4, 7, 3},
+		{0, 0, 5, 0, 0},
+		{2, 3, 5, 0, 5},
+		{12, 13, 5, 10, 15},
+		{5, 10, 5, 5, 10},
+		{1, 2, 4, 0, 4},
+		{-3, -2, 4, -4, 0},
+	}
+	for _, test := range tests {
+		if x, y := snapPoint(test.x, test.y, test.size); x != test.wantX || y != test.wantY {
+			t.Errorf("snapPoint(%d, %d, %d) = %d, %d, want %d, %d", test.x, test.y, test.size, x, y, test.wantX, test.wantY)
+		}
+	}
+}
+
+func TestSnapped(t *testing.T) {
+	m := testModel(8, 8)
+	m.gridSize, m.tool = 5, toolLine
+	if x, y := m.snapped(7, 3); x != 7 || y != 3 {
+		t.Errorf("snapped with snapping off: got %d, %d", x, y)
+	}
+	m.snap = true
+	if x, y := m.snapped(7, 3); x != 5 || y != 5 {
+		t.Errorf("got %d, %d, want the intersection on the canvas, 5, 5", x, y)
+	}
+	m.tool = toolPaint
+	if x, y := m.snapped(7, 3); x != 7 || y != 3 {
+		t.Errorf("snapped painting: got %d, %d", x, y)
+	}
+}
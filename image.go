@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// The average color of each cell, when img is scaled to w x h cells, as
+// red, green and blue from 0 to 1.
+//
+func downsample(img image.Image, w, h int) [][][3]float64 {
+	bounds := img.Bounds()
+	out := make([][][3]float64, h)
+	for y := range out {
+		out[y] = make([][3]float64, w)
+		y0 := bounds.Min.Y + y * bounds.Dy() / h
+		y1 := max(bounds.Min.Y + (y + 1) * bounds.Dy() / h, y0 + 1)
+		for x := range out[y] {
+			x0 := bounds.Min.X + x * bounds.Dx() / w
+			x1 := max(bounds.Min.X + (x + 1) * bounds.Dx() / w, x0 + 1)
+			var sum [3]float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum[0] += float64(r) / 0xffff
+					sum[1] += float64(g) / 0xffff
+					sum[2] += float64(b) / 0xffff
+				}
+			}
+			n := float64((y1 - y0) * (x1 - x0))
+			out[y][x] = [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}
+		}
+	}
+	return out
+}
+
+func luminance(rgb [3]float64) float64 {
+	return 0.2126 * rgb[0] + 0.7152 * rgb[1] + 0.0722 * rgb[2]
+}
+
+//
+// Shade img into a w x h canvas.  The ramp goes from dark to bright, as
+// seen on a dark terminal, so it starts with the emptiest character.  With
+// dither, errors in brightness are spread to the cells not yet shaded
+// (Floyd-Steinberg), which keeps smooth gradients from turning into bands.
+//
+func imageToCanvas(img image.Image, w, h int, ramp []rune, dither bool) Canvas {
+	cells := downsample(img, w, h)
+	levels := make([][]float64, h)
+	for y := range levels {
+		levels[y] = make([]float64, w)
+		for x := range levels[y] {
+			levels[y][x] = luminance(cells[y][x])
+		}
+	}
+	spread := func(x, y int, err float64) {
+		if x >= 0 && x < w && y < h {
+			levels[y][x] += err
+		}
+	}
+
+	canvas := canvas.New(w, h)
+	steps := float64(len(ramp) - 1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := clamp(int(levels[y][x] * steps + 0.5), 0, len(ramp) - 1)
+			canvas.Cells()[y][x] = pixel{R: ramp[i]}
+			if dither && steps > 0 {
+				err := levels[y][x] - float64(i) / steps
+				spread(x + 1, y, err * 7 / 16)
+				spread(x - 1, y + 1, err * 3 / 16)
+				spread(x, y + 1, err * 5 / 16)
+				spread(x + 1, y + 1, err * 1 / 16)
+			}
+		}
+	}
+	return canvas
+}
+
+//
+// Color each cell of canvas like the part of img that it covers.
+//
+func tintFromImage(canvas Canvas, img image.Image) {
+	cells := downsample(img, canvas.Width(), canvas.Height())
+	for y, row := range canvas.Cells() {
+		for x := range row {
+			rgb := cells[y][x]
+			row[x].FG = color(fmt.Sprintf("#%02x%02x%02x", int(rgb[0] * 255 + 0.5), int(rgb[1] * 255 + 0.5), int(rgb[2] * 255 + 0.5)))
+		}
+	}
+}
+
+//
+// Load an image for :open, with options after the filename, e.g.
+// "photo.jpg dither color".
+//
+func openImage(arg string, w, h int, ramp []rune) tea.Msg {
+	fields := strings.Fields(arg)
+	dither, tint := false, false
+	for len(fields) > 1 {
+		if last := fields[len(fields)-1]; last == "dither" {
+			dither = true
+		} else if last == "color" {
+			tint = true
+		} else {
+			break
+		}
+		fields = fields[:len(fields)-1]
+	}
+	filename := strings.Join(fields, " ")
+
+	fin, err := os.Open(filename)
+	if err != nil {
+		return errMsg{err}
+	}
+	defer fin.Close()
+	img, _, err := image.Decode(fin)
+	if err != nil {
+		return errMsg{fmt.Errorf("%s: %w", filename, err)}
+	}
+	if len(ramp) == 0 {
+		ramp = defaultShadeRamp
+	}
+	canvas := imageToCanvas(img, w, h, ramp, dither)
+	if tint {
+		tintFromImage(canvas, img)
+	}
+
+	//
+	// Like :import, don't remember the name.
+	//
+	return canvasLoadedMsg{w, h, []layer{{Grid: canvas, Visible: true}}, "", nil}
+}
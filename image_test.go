@@ -0,0 +1,53 @@
+package main
+
+import (
+	"image"
+	imagecolor "image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//
+// A w x h image with every pixel of the given gray level.
+//
+func grayImage(w, h int, level uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = level
+	}
+	return img
+}
+
+func TestImageToCanvas(t *testing.T) {
+	ramp := []rune(" .:#")
+
+	ramped := image.NewGray(image.Rect(0, 0, 8, 2))
+	for x := 0; x < 8; x++ {
+		ramped.SetGray(x, 0, imagecolor.Gray{uint8(x * 255 / 7)})
+		ramped.SetGray(x, 1, imagecolor.Gray{uint8(x * 255 / 7)})
+	}
+	if got, want := regionRows(imageToCanvas(ramped, 4, 1, ramp, false).Cells()), []string{" .:#"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dark to bright: got %q, want %q", got, want)
+	}
+
+	checkered := image.NewGray(image.Rect(0, 0, 2, 2))
+	checkered.SetGray(0, 0, imagecolor.Gray{255})
+	checkered.SetGray(1, 1, imagecolor.Gray{255})
+	if got, want := regionRows(imageToCanvas(checkered, 1, 1, []rune(" #"), false).Cells()), []string{"#"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("averaged: got %q, want %q", got, want)
+	}
+
+	//
+	// A mid gray is all one glyph without dithering, and half and half
+	// with it.
+	//
+	gray := grayImage(16, 16, 128)
+	if got := regionRows(imageToCanvas(gray, 8, 4, []rune(" #"), false).Cells()); strings.Join(got, "") != strings.Repeat("#", 32) {
+		t.Errorf("bands: got %q", got)
+	}
+	dithered := strings.Join(regionRows(imageToCanvas(gray, 8, 4, []rune(" #"), true).Cells()), "")
+	if n := strings.Count(dithered, "#"); n < 14 || n > 18 {
+		t.Errorf("dithered: got %d of 32 filled, want about half: %q", n, dithered)
+	}
+}
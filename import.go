@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+const defaultTabWidth = 8
+
+//
+// Read an arbitrary text file into a canvas just big enough to hold it.
+// Tabs are expanded to the next multiple of tabWidth, and short lines are
+// padded with spaces.
+//
+func importText(fin io.Reader, tabWidth int) (Canvas, error) {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	var rows [][]pixel
+	width := 0
+	scanner := bufio.NewScanner(fin)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		var row []pixel
+		for _, r := range line {
+			switch {
+			case r == '\t':
+				for {
+					row = append(row, pixel{R: ' '})
+					if len(row) % tabWidth == 0 {
+						break
+					}
+				}
+			case !unicode.IsGraphic(r):
+				row = append(row, pixel{R: ' '})
+			case canvas.IsWide(r):
+				row = append(row, pixel{R: r}, padding)
+			default:
+				row = append(row, pixel{R: r})
+			}
+		}
+		width = max(width, len(row))
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return Canvas{}, err
+	}
+	return canvas.Of(rows, width, len(rows), pixel{R: ' '}), nil
+}
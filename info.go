@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// How many glyphs :info lists.
+//
+const infoGlyphs = 5
+
+type glyphCount struct {
+	r rune
+	n int
+}
+
+type canvasInfo struct {
+	width, height int
+
+	//
+	// Cells other than blanks, and the glyphs in them, most used first.
+	//
+	filled int
+	glyphs []glyphCount
+}
+
+func canvasStats(canvas Canvas) canvasInfo {
+	stats := canvasInfo{width: canvas.Width(), height: canvas.Height()}
+	counts := map[rune]int{}
+	for _, row := range canvas.Cells() {
+		for _, p := range row {
+			if !p.IsBlank() {
+				counts[p.R]++
+				stats.filled++
+			}
+		}
+	}
+	for r, n := range counts {
+		stats.glyphs = append(stats.glyphs, glyphCount{r, n})
+	}
+	sort.Slice(stats.glyphs, func(i, j int) bool {
+		a, b := stats.glyphs[i], stats.glyphs[j]
+		return a.n > b.n || (a.n == b.n && a.r < b.r)
+	})
+	return stats
+}
+
+//
+// Counts what's written to it, for sizing a save without making one.
+//
+type byteCounter int
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+func formatSize(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	return fmt.Sprintf("%.1f KiB", float64(n) / 1024)
+}
+
+//
+// What's visible, e.g. "80x50, 312 filled: # 200  █ 80  ─ 32, 4.1 KiB
+// saved".
+//
+func info(m model) tea.Msg {
+	stats := canvasStats(canvas.Composite(m.layers, m.width, m.height))
+	var glyphs []string
+	for i, g := range stats.glyphs {
+		if i == infoGlyphs {
+			glyphs = append(glyphs, "…")
+			break
+		}
+		glyphs = append(glyphs, fmt.Sprintf("%c %d", g.r, g.n))
+	}
+	var size byteCounter
+	if err := canvas.Save(m.layers, m.width, m.height, m.marks, m.trimSafe, &size); err != nil {
+		return errMsg{err}
+	}
+
+	text := fmt.Sprintf("%dx%d, %d filled", stats.width, stats.height, stats.filled)
+	if len(glyphs) > 0 {
+		text += ": " + strings.Join(glyphs, "  ")
+	}
+	return statusMsg{fmt.Sprintf("%s, %s saved", text, formatSize(int(size)))}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanvasStats(t *testing.T) {
+	c := canvasOf(
+		"ab a.",
+		"世b  ",
+	)
+	c.Set(3, 1, pixel{R: ' ', BG: "1"})
+	stats := canvasStats(c)
+	if stats.width != 5 || stats.height != 2 {
+		t.Errorf("got %dx%d, want 5x2", stats.width, stats.height)
+	}
+	if stats.filled != 6 {
+		t.Errorf("got %d filled, want 6", stats.filled)
+	}
+	want := []glyphCount{{'a', 2}, {'b', 2}, {' ', 1}, {'世', 1}}
+	if !reflect.DeepEqual(stats.glyphs, want) {
+		t.Errorf("got %v, want %v", stats.glyphs, want)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+//
+// Things that a key can do outside command mode, named as in the [keys]
+// section of the config file.
+//
+const (
+	actionCommand = "command"
+	actionRepeat = "repeat"
+	actionQuit = "quit"
+	actionCancel = "cancel"
+	actionLeft = "left"
+	actionRight = "right"
+	actionUp = "up"
+	actionDown = "down"
+	actionPanLeft = "pan-left"
+	actionPanRight = "pan-right"
+	actionPanUp = "pan-up"
+	actionPanDown = "pan-down"
+	actionPaint = "paint"
+	actionUndo = "undo"
+	actionRedo = "redo"
+	actionPick = "pick"
+	actionPour = "pour"
+	actionSwap = "swap"
+	actionRecent = "recent"
+	actionErase = "erase"
+	actionLighter = "lighter"
+	actionDarker = "darker"
+	actionSmaller = "smaller"
+	actionBigger = "bigger"
+)
+
+//
+// The keys bound to each action, as bubbletea names them.
+//
+var defaultBindings = map[string][]string{
+	actionCommand: {":"},
+	actionRepeat: {"."},
+	actionQuit: {"ctrl+c", "q"},
+	actionCancel: {"esc"},
+	actionLeft: {"left", "h"},
+	actionRight: {"right", "l"},
+	actionUp: {"up", "k"},
+	actionDown: {"down", "j"},
+	actionPanLeft: {"shift+left"},
+	actionPanRight: {"shift+right"},
+	actionPanUp: {"shift+up"},
+	actionPanDown: {"shift+down"},
+	actionPaint: {" ", "enter"},
+	actionUndo: {"u"},
+	actionRedo: {"ctrl+r"},
+	actionPick: {"i"},
+	actionPour: {"ctrl+v"},
+	actionSwap: {"x"},
+	actionRecent: {"ctrl+p"},
+	actionErase: {"e"},
+	actionLighter: {"<"},
+	actionDarker: {">"},
+	actionSmaller: {"["},
+	actionBigger: {"]"},
+}
+
+//
+// Maps keys to the actions they're bound to.  Keys that aren't bound to
+// anything select the primary brush.
+//
+type keymap map[string]string
+
+//
+// The default bindings, with those of the actions in overrides replaced.
+// A key that's rebound to another action is taken away from its default.
+//
+func newKeymap(overrides map[string][]string) (keymap, error) {
+	keys := keymap{}
+	for action, bound := range defaultBindings {
+		if _, ok := overrides[action]; ok {
+			continue
+		}
+		for _, key := range bound {
+			keys[key] = action
+		}
+	}
+
+	//
+	// Go in a fixed order, so that conflicting overrides resolve the same
+	// way every time.
+	//
+	actions := make([]string, 0, len(overrides))
+	for action := range overrides {
+		if _, ok := defaultBindings[action]; !ok {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		for _, key := range overrides[action] {
+			keys[key] = action
+		}
+	}
+	return keys, nil
+}
+
+func (k keymap) action(key string) string {
+	return k[key]
+}
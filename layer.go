@@ -0,0 +1,46 @@
+package main
+
+//
+// The grid that drawing operations apply to.
+//
+func (m model) canvas() Canvas {
+	return m.layers[m.activeLayer].Grid
+}
+
+//
+// Whether (x, y) is a cell of the active layer.  That's checked against
+// the grid itself rather than m.width and m.height, in case the two ever
+// disagree.
+//
+func (m model) inCanvas(x, y int) bool {
+	return m.canvas().In(x, y)
+}
+
+func (m *model) moveLayer(dx, dy int, wrap bool) {
+	m.pushHistory()
+	m.layers[m.activeLayer].Grid = m.canvas().Shift(dx, dy, wrap)
+}
+
+//
+// Roll every layer, unlike :move, which only moves the active one.
+//
+func (m *model) scroll(dx, dy int) {
+	m.pushHistory()
+	for i := range m.layers {
+		m.layers[i].Grid = m.layers[i].Grid.Scroll(dx, dy)
+	}
+}
+
+func arrowDelta(key string) (dx, dy int) {
+	switch key {
+	case "left":
+		return -1, 0
+	case "right":
+		return 1, 0
+	case "up":
+		return 0, -1
+	case "down":
+		return 0, 1
+	}
+	return 0, 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func click(m model, x, y int) model {
+	for _, action := range []tea.MouseAction{tea.MouseActionPress, tea.MouseActionMotion, tea.MouseActionRelease} {
+		m = update(m, tea.MouseMsg{X: x, Y: y, Button: tea.MouseButtonLeft, Action: action})
+	}
+	return m
+}
+
+//
+// A file cut off partway through doesn't load, and clicking anywhere
+// afterwards, on the canvas or off it, doesn't panic.
+//
+func TestClickAfterTruncatedLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "truncated.txt")
+	if err := os.WriteFile(filename, []byte("gopnik v2\nwidth 4\nheight 3\n\nabcd\nab"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := testModel(6, 5)
+	m.termWidth, m.termHeight = 20, 10
+	if _, ok := interpretCmd(m, "load " + filename)().(errMsg); !ok {
+		t.Fatalf("loaded a truncated file")
+	}
+	for _, p := range [][2]int{{0, 0}, {5, 4}, {6, 5}, {19, 9}, {-1, -1}} {
+		m = click(m, p[0], p[1])
+	}
+	if m.canvas().At(5, 4) != m.brushPrimary {
+		t.Errorf("didn't paint the last cell")
+	}
+}
+
+//
+// Should the size and the layer ever disagree, painting goes by the layer.
+//
+func TestInCanvas(t *testing.T) {
+	m := testModel(6, 5)
+	m.termWidth, m.termHeight = 20, 10
+	m.layers[0].Grid = canvas.New(3, 2)
+	for _, test := range []struct {
+		x, y int
+		want bool
+	}{
+		{0, 0, true},
+		{2, 1, true},
+		{3, 1, false},
+		{2, 2, false},
+		{5, 4, false},
+		{-1, 0, false},
+	} {
+		if got := m.inCanvas(test.x, test.y); got != test.want {
+			t.Errorf("inCanvas(%d, %d) = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+	m = click(m, 5, 4)
+	m = click(m, 2, 1)
+	if m.canvas().At(2, 1) != m.brushPrimary {
+		t.Errorf("didn't paint the last cell of the layer")
+	}
+}
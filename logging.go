@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+//
+// How much goes to the log.  Errors, warnings and the odd notable event are
+// logged whenever logging is on, the chatter about every message only with -debug.
+//
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelWarning
+	levelInfo
+	levelDebug
+)
+
+var levelNames = [...]string{"error", "warning", "info", "debug"}
+
+var logThreshold = levelInfo
+
+func logf(level logLevel, format string, args ...any) {
+	if level > logThreshold {
+		return
+	}
+	log.Printf("%s: %s", levelNames[level], fmt.Sprintf(format, args...))
+}
+
+func errorf(format string, args ...any) {
+	logf(levelError, format, args...)
+}
+
+func warningf(format string, args ...any) {
+	logf(levelWarning, format, args...)
+}
+
+func infof(format string, args ...any) {
+	logf(levelInfo, format, args...)
+}
+
+func debugf(format string, args ...any) {
+	logf(levelDebug, format, args...)
+}
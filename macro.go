@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//
+// Start recording into register, or stop recording if it's "".
+//
+type recordMsg struct {
+	register string
+}
+
+type playMsg struct {
+	register string
+	count int
+}
+
+const maxPlayCount = 1000
+
+func recordCommand(m model, arg string) tea.Msg {
+	if strings.ContainsAny(arg, " \t") {
+		return errMsg{errUsage}
+	} else if arg == "end" {
+		return recordMsg{""}
+	}
+	return recordMsg{arg}
+}
+
+func playCommand(m model, arg string) tea.Msg {
+	args := strings.Fields(arg)
+	if len(args) < 1 || len(args) > 2 {
+		return errMsg{errUsage}
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > maxPlayCount {
+			return errMsg{fmt.Errorf("bad count %q: expected 1-%d", args[1], maxPlayCount)}
+		}
+		count = n
+	}
+	return playMsg{args[0], count}
+}
+
+func (m *model) record(register string) tea.Cmd {
+	switch {
+	case register == "" && m.recording == "":
+		return m.setStatus("not recording", true)
+	case register == "":
+		macros := maps.Clone(m.macros)
+		if macros == nil {
+			macros = map[string][]string{}
+		}
+		macros[m.recording] = m.recorded
+		m.macros = macros
+		text := fmt.Sprintf("recorded %d steps into @%s", len(m.recorded), m.recording)
+		m.recording, m.recorded = "", nil
+		return m.setStatus(text, false)
+	case m.recording != "":
+		return m.setStatus(fmt.Sprintf("already recording @%s", m.recording), true)
+	}
+	m.recording, m.recorded = register, nil
+	return m.setStatus(fmt.Sprintf("recording @%s, :record end to stop", register), false)
+}
+
+//
+// Commands typed while recording are recorded as they were typed, apart
+// from the :record that ends the recording.
+//
+func (m *model) recordCommand(buffer string) {
+	if m.recording == "" {
+		return
+	}
+	if split := splitCommands(buffer); len(split) > 0 {
+		verb, _, _ := strings.Cut(split[0], " ")
+		if name, _ := commandName(verb); name == "record" {
+			return
+		}
+	}
+	m.recorded = append(m.recorded, buffer)
+}
+
+//
+// Keys pressed while recording, other than those typed into the command
+// line, are recorded as :key commands.  Mouse clicks aren't recorded.
+//
+func (m *model) recordKey(msg tea.KeyMsg) {
+	if m.recording == "" || m.commandActive || msg.Paste {
+		return
+	}
+	typing := m.tool == toolText && m.textActive
+	if !typing && m.keys.action(msg.String()) == actionCommand {
+		return
+	}
+	m.recorded = append(m.recorded, "key " + strings.ReplaceAll(keyName(msg), "|", "\\|"))
+}
+
+//
+// Run the macro in register count times over, as a single undo step.
+//
+func (m model) play(register string, count int) (tea.Model, tea.Cmd) {
+	steps, ok := m.macros[register]
+	if !ok {
+		return m, m.setStatus(fmt.Sprintf("nothing recorded in @%s", register), true)
+	} else if m.recording != "" {
+		//
+		// A macro that played itself would never end.
+		//
+		return m, m.setStatus("can't play a macro while recording one", true)
+	}
+	var lines []commandLine
+	for i := 0; i < count; i++ {
+		for n, step := range steps {
+			for _, text := range splitCommands(step) {
+				lines = append(lines, commandLine{n + 1, text})
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return m, nil
+	}
+	m.historyGroup, m.historyGrouped = true, false
+	return m, nextInChain(m, chainMsg{script: "@" + register, rest: lines, grouped: true})
+}
+
+func (m *model) endHistoryGroup() {
+	m.historyGroup, m.historyGrouped = false, false
+}
+
+//
+// Key types by the names that bubbletea gives them.
+//
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{}
+	for t := tea.KeyType(-128); t < 128; t++ {
+		if name := t.String(); name != "" && t != tea.KeyRunes {
+			types[name] = t
+		}
+	}
+	return types
+}()
+
+//
+// The name of the key, as in the [keys] section of the config, with
+// "space" for the space bar, which would otherwise be lost to trimming.
+//
+func keyName(msg tea.KeyMsg) string {
+	if msg.String() == " " {
+		return "space"
+	}
+	return msg.String()
+}
+
+func parseKey(name string) (tea.KeyMsg, error) {
+	alt := false
+	if rest, ok := strings.CutPrefix(name, "alt+"); ok && rest != "" {
+		name, alt = rest, true
+	}
+	if name == "space" {
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}, Alt: alt}, nil
+	} else if t, ok := keyTypes[name]; ok {
+		return tea.KeyMsg{Type: t, Alt: alt}, nil
+	} else if runes := []rune(name); len(runes) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: runes, Alt: alt}, nil
+	}
+	return tea.KeyMsg{}, fmt.Errorf("unknown key %q", name)
+}
+
+//
+// Press a key as if it was typed, for macros and scripts.
+//
+func keyCommand(m model, arg string) tea.Msg {
+	msg, err := parseKey(arg)
+	if err != nil {
+		return errMsg{err}
+	}
+	return msg
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMacro(t *testing.T) {
+	m := run(t, testModel(4, 1), "record a")
+	for _, typed := range []string{"brush x", "resize 5 1 | resize 6 1", "record end"} {
+		m.recordCommand(typed)
+	}
+	m = run(t, m, "record end")
+	if got, want := m.macros["a"], []string{"brush x", "resize 5 1 | resize 6 1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recorded %q, want %q", got, want)
+	}
+
+	m = run(t, m, "brush y", "play a 2")
+	if m.brushPrimary.R != 'x' || m.width != 6 {
+		t.Errorf("got brush %c and width %d, want x and 6", m.brushPrimary.R, m.width)
+	}
+	m = run(t, m, "undo")
+	if m.width != 4 {
+		t.Errorf("got width %d after undoing the macro, want 4", m.width)
+	}
+
+	for _, test := range []struct {
+		line, want string
+	}{
+		{"play b", "nothing recorded in @b"},
+		{"play a 0", "bad count \"0\": expected 1-1000"},
+	} {
+		if _, err := m.apply(interpretCmd(m, test.line)()); err == nil || err.Error() != test.want {
+			t.Errorf(":%s: got %v, want %s", test.line, err, test.want)
+		}
+	}
+}
@@ -1,53 +1,57 @@
 package main
 
 //
-// - [ ] Pallette of useful character sets, e.g. for box drawing, click to select
-// - [ ] Primary/secondary brush, left/right mouse button, swap with some hotkey
-// - [ ] Brush size
+// - [x] Pallette of useful character sets, e.g. for box drawing, click to select
+// - [x] Primary/secondary brush, left/right mouse button, swap with some hotkey
+// - [x] Brush size
 // - [x] Enter text commands, like : in vim
 // - [x] Save-load functionality
-// - [ ] Coloring
-// - [ ] Undo and redo
-// - [ ] Draw a border around the canvas
-// - [ ] Layers and transparency
-// - [ ] Move layers around
+// - [x] Coloring
+// - [x] Undo and redo
+// - [x] Draw a border around the canvas
+// - [x] Layers and transparency
+// - [x] Move layers around
 // - [ ] On-screen ruler
 //
 
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
 )
 
-type pixel rune
-
-func newCanvas(width, height int) [][]pixel {
-	c := make([][]pixel, height)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			c[y] = append(c[y], ' ')
+//
+// Make every layer width x height, cutting rows short or padding them out,
+// the bottom layer with spaces and the rest with transparency, like
+// resizing does.
+//
+func normalizeLayers(layers []layer, width, height int) {
+	for i := range layers {
+		fill := transparent
+		if i == 0 {
+			fill = pixel{R: ' '}
 		}
+		layers[i].Grid = canvas.Of(layers[i].Grid.Cells(), width, height, fill)
 	}
-	return c
 }
 
-func newTestCanvas(width, height int) [][]pixel {
-	c := make([][]pixel, height)
+func newTestCanvas(width, height int) Canvas {
+	c := canvas.New(width, height)
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			if (x + y) % 2 == 0 {
-				c[y] = append(c[y], ' ')
-			} else {
-				c[y] = append(c[y], '#')
+			if (x + y) % 2 != 0 {
+				c.Cells()[y][x] = pixel{R: '#'}
 			}
 		}
 	}
@@ -55,56 +59,740 @@ func newTestCanvas(width, height int) [][]pixel {
 }
 
 type model struct {
-	width int
-	height int
-	canvas [][]pixel
-	brush pixel
+	//
+	// The drawing being edited, and the others open in tabs.  The active
+	// one is kept embedded, with tabs[activeTab] brought up to date when
+	// switching away from it.
+	//
+	document
+	tabs []document
+	activeTab int
+
+	brushSize int
+	brushShape brushShape
+	mirror mirrorMode
+	wrap bool
+
+	//
+	// While erasing, both mouse buttons (and the keyboard) paint blanks,
+	// leaving the brushes as they were for when erasing is toggled off.
+	//
+	erasing bool
+	autoConnect bool
+
+	//
+	// Highlight box-drawing glyphs that connect to nothing, and count them
+	// in the status bar.
+	//
+	showDangling bool
+
+	//
+	// What toolStamp paints, and whether spaces in it (and in pastes)
+	// leave the canvas untouched, like transparent cells do.
+	//
+	stampBrush [][]pixel
+	transparentBlanks bool
+
+	//
+	// The spray can's settings, and while the button is down, what it's
+	// spraying.  spraySeed advances with every burst.
+	//
+	sprayRadius, sprayDensity int
+	sprayBrush pixel
+	spraying bool
+	sprayID int
+	spraySeed int64
+
+	//
+	// The glyphs that toolGradient shades with, lightest first, and the
+	// direction in which it does.
+	//
+	gradientRamp []rune
+	gradientDirection gradientDirection
+
+	//
+	// The glyphs that < and > step the primary brush through, and where in
+	// them it was last.
+	//
+	shadeRamp []rune
+	shadeIndex int
+
+	//
+	// The brushes most recently chosen, newest first, which the recent key
+	// steps back through, and where in them it was last.
+	//
+	recentBrushes []pixel
+	recentIndex int
+
+	//
+	// How much of a ░▒▓█ brush goes on, from above 0 to 1, for building
+	// up shading a stroke at a time.  Zero means 1.
+	//
+	ink float64
 
 	commandBuffer string
 	commandActive bool
+	commandHistory []string
+
+	//
+	// What "." repeats: the last command run this session.
+	//
+	lastCommand string
+	commandHistoryCursor int
+
+	//
+	// Set while Tab is cycling through ambiguous completions.
+	//
+	completions []string
+	completionHead string
+	completionIndex int
+
+	//
+	// Shown in place of the command line for a few seconds.  statusID tells
+	// apart messages, so that a timer doesn't clear a newer one.
+	//
+	status string
+	statusErr bool
+	statusID int
+
+	//
+	// Set when running a script without the editor, where there's nobody
+	// to clear the status for.
+	//
+	headless bool
+
+	tool tool
+	moveMode bool
+	fillDiagonal bool
+	anchorSet bool
+	anchorX int
+	anchorY int
+
+	//
+	// For a polyline, the anchor is the last vertex.  These are the first
+	// one, whether ending the path goes back to it, and whether any of it
+	// has been drawn yet, which is when the undo step is made.
+	//
+	polyStartX, polyStartY int
+	polyClosed bool
+	polyDrawn bool
+
+	palette [][]rune
+	paletteVisible bool
+
+	//
+	// Whether to show the minimap of canvases bigger than the view.
+	//
+	minimapVisible bool
+
+	//
+	// How many colors the color picker shows, or zero when it's hidden.
+	//
+	colorsVisible int
+
+	//
+	// Gridlines are every gridSize cells, or nowhere if that's zero.  With
+	// snap, shapes go from gridline to gridline.
+	//
+	gridSize int
+	gridRune rune
+	snap bool
+
+	//
+	// Lines go at 45 degree steps with ortho on, or while shift is held
+	// down, which not every terminal reports with mouse events.
+	//
+	ortho bool
+	shiftHeld bool
+
+	//
+	// Lines are drawn in -|\/ (or ─│╲╱) along their direction with smooth
+	// on, rather than in the brush.
+	//
+	smooth bool
+
+	tabWidth int
+
+	//
+	// How often dirty canvases are backed up, or never if zero.
+	//
+	autosaveInterval time.Duration
+	autosaveID int
+
+	//
+	// What :save - saved, for writing to stdout once the terminal's free.
+	//
+	stdout []byte
+
+	//
+	// Save so that stripping trailing whitespace doesn't break the file,
+	// see canvas.Save.
+	//
+	trimSafe bool
+
+	clipboard [][]pixel
+
+	//
+	// Where the text tool types next, separate from the keyboard cursor.
+	// Enter goes back to textColumn on the next row.
+	//
+	textX int
+	textY int
+	textColumn int
+	textActive bool
+
+	//
+	// Where the mouse was last seen, for previews.
+	//
+	mouseX int
+	mouseY int
+
+	//
+	// The size of the terminal, if known.
+	//
+	termWidth, termHeight int
+
+	historyDepth int
+
+	//
+	// While a macro plays, everything it does is one undo step: the first
+	// pushHistory makes it, once historyGrouped is set the rest are skipped.
+	//
+	historyGroup bool
+	historyGrouped bool
+
+	//
+	// Macros by register, and the register being recorded into, if any,
+	// along with what's been recorded so far.
+	//
+	macros map[string][]string
+	recording string
+	recorded []string
+
+	//
+	// A file to open as soon as the program starts.  When sniff is set,
+	// it may be plain text or not exist yet, as with a file named on the
+	// command line.
+	//
+	startupFile string
+	startupSniff bool
+
+	//
+	// Make the blank canvas fit the terminal once its size is known.
+	//
+	fit bool
+
+	keys keymap
+
+	rows *rowCache
+}
+
+const defaultHistoryDepth = 100
+
+const (
+	defaultWidth = 80
+	defaultHeight = 50
+)
+
+//
+// Call this before mutating the canvas.  Doing so marks the canvas dirty.
+//
+func (m *model) pushHistory() {
+	m.dirty, m.backupStale = true, true
+	if m.historyGroup && m.historyGrouped {
+		return
+	}
+	m.historyGrouped = m.historyGroup
+	m.history = append(m.history[:m.historyIndex], canvas.CloneLayers(m.layers))
+	if m.historyDepth > 0 && len(m.history) > m.historyDepth {
+		m.history = m.history[len(m.history)-m.historyDepth:]
+	}
+	m.historyIndex = len(m.history)
+}
+
+func (m *model) restoreHistory(index int) {
+	m.dirty, m.backupStale = true, true
+	m.historyIndex = index
+	m.layers = canvas.CloneLayers(m.history[index])
+	if m.activeLayer >= len(m.layers) {
+		m.activeLayer = len(m.layers) - 1
+	}
+	m.width, m.height = m.layers[0].Grid.Bounds()
+}
+
+func (m *model) undo() {
+	if m.historyIndex == 0 {
+		return
+	}
+	if m.historyIndex == len(m.history) {
+		//
+		// Keep the current state around so that we can redo back to it.
+		//
+		m.history = append(m.history, canvas.CloneLayers(m.layers))
+	}
+	m.restoreHistory(m.historyIndex - 1)
+}
+
+func (m *model) redo() {
+	if m.historyIndex + 1 >= len(m.history) {
+		return
+	}
+	m.restoreHistory(m.historyIndex + 1)
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(tea.EnableMouseAllMotion, tea.ClearScreen)
+	cmds := []tea.Cmd{tea.EnableMouseAllMotion, tea.ClearScreen}
+	if m.startupFile != "" {
+		filename, sniff, tabWidth := m.startupFile, m.startupSniff, m.tabWidth
+		cmds = append(cmds, func() tea.Msg {
+			if sniff {
+				return openFile(filename, tabWidth)
+			}
+			return loadFile(filename)
+		}, func() tea.Msg {
+			return checkBackup(filename)
+		})
+	}
+	cmds = append(cmds, m.autosaveTick())
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	log.Printf("msg: %q %T", msg, msg)
+	debugf("msg: %q %T", msg, msg)
 	switch msg := msg.(type) {
 	case quitMsg:
-		return m, tea.Quit
+		return m.quit(msg.force)
+	case chainMsg:
+		return m.continueChain(msg)
+	case sourceMsg:
+		return m, runChain(m, msg.script, msg.lines)
+	case errMsg:
+		errorf("%v", msg.err)
+		return m, m.setStatus(msg.err.Error(), true)
+	case statusMsg:
+		return m, m.setStatus(msg.text, false)
+	case statusClearedMsg:
+		if msg.id == m.statusID {
+			m.status = ""
+		}
+		return m, nil
+	case savedMsg:
+		m.dirty = false
+		m.filename = msg.filename
+		return m, m.setStatus("saved " + msg.filename, false)
+	case stdoutSavedMsg:
+		m.stdout = msg.data
+		m.dirty = false
+		m.filename = stdioName
+		return m, m.setStatus("saved, to be written to stdout on exit", false)
+	case undoMsg:
+		m.undo()
+		return m, nil
+	case autosaveChangedMsg:
+		m.autosaveInterval = msg.interval
+		return m, m.scheduleAutosave()
+	case autosaveTickMsg:
+		if msg.id != m.autosaveID {
+			return m, nil
+		}
+		return m, tea.Batch(m.backup(), m.autosaveTick())
+	case recoveredMsg:
+		m.pushHistory()
+		m.width, m.height = msg.width, msg.height
+		m.layers = msg.layers
+		normalizeLayers(m.layers, m.width, m.height)
+		m.activeLayer = 0
+		m.filename = msg.filename
+		return m, m.setStatus("recovered backup, :w to keep it", false)
+	case pourMsg:
+		if err := m.pour(msg.text); err != nil {
+			return m, m.setStatus(err.Error(), true)
+		}
+		return m, nil
+	case tabNewMsg:
+		m.newTab()
+		if msg.filename == "" {
+			return m, nil
+		}
+		filename, tabWidth := msg.filename, m.tabWidth
+		return m, func() tea.Msg {
+			return openFile(filename, tabWidth)
+		}
+	case tabSwitchMsg:
+		m.cycleTab(msg.delta)
+		return m, nil
+	case tabCloseMsg:
+		if err := m.closeTab(msg.force); err != nil {
+			return m, m.setStatus(err.Error(), true)
+		}
+		return m, nil
+	case redoMsg:
+		m.redo()
+		return m, nil
+	case newFileMsg:
+		m.filename = msg.filename
+		return m, m.setStatus(fmt.Sprintf("%s: new file", msg.filename), false)
 	case canvasLoadedMsg:
+		m.pushHistory()
 		m.width = msg.width
 		m.height = msg.height
-		m.canvas = msg.canvas
+		m.layers = msg.layers
+		normalizeLayers(m.layers, m.width, m.height)
+		m.activeLayer = 0
+		m.dirty = false
+		m.filename = msg.filename
+		m.marks = msg.marks
+		return m, nil
+	case bgChangedMsg:
+		m.brushPrimary.BG = msg.color
+		return m, nil
+	case attrChangedMsg:
+		if msg.on {
+			m.brushPrimary.Attrs |= msg.attr
+		} else {
+			m.brushPrimary.Attrs &^= msg.attr
+		}
 		return m, nil
 	case brushChangedMsg:
-		m.brush = msg.brush
+		if msg.slot == 2 {
+			m.rememberBrush(m.brushSecondary)
+			m.brushSecondary = msg.brush
+		} else {
+			m.rememberBrush(m.brushPrimary)
+			m.brushPrimary = msg.brush
+		}
+		m.rememberBrush(msg.brush)
+		return m, nil
+	case colorChangedMsg:
+		m.brushPrimary.FG = msg.color
+		return m, nil
+	case brushSizeChangedMsg:
+		m.brushSize = msg.size
+		return m, nil
+	case brushShapeChangedMsg:
+		m.brushShape = msg.shape
+		return m, nil
+	case autoConnectChangedMsg:
+		m.autoConnect = msg.autoConnect
+		return m, nil
+	case wrapChangedMsg:
+		m.wrap = msg.wrap
+		return m, nil
+	case trimSafeChangedMsg:
+		m.trimSafe = msg.on
+		return m, nil
+	case snapChangedMsg:
+		m.snap = msg.snap
+		if m.snap && m.gridSize <= 0 {
+			return m, m.setStatus("nothing to snap to until there's a grid, see :grid", false)
+		}
+		return m, nil
+	case inkChangedMsg:
+		m.ink = msg.opacity
+		return m, nil
+	case danglingChangedMsg:
+		m.showDangling = msg.on
+		return m, nil
+	case smoothChangedMsg:
+		m.smooth = msg.smooth
+		return m, nil
+	case orthoChangedMsg:
+		m.ortho = msg.ortho
+		return m, nil
+	case mirrorChangedMsg:
+		m.mirror = msg.mode
+		return m, nil
+	case fillArmedMsg:
+		m.tool = toolFill
+		m.fillDiagonal = msg.diagonal
+		return m, nil
+	case borderMsg:
+		m.pushHistory()
+		drawBorder(m.canvas(), msg.glyphs, m.brushPrimary.FG)
+		return m, nil
+	case layerNewMsg:
+		m.pushHistory()
+		m.layers = append(m.layers, canvas.NewLayer(m.width, m.height))
+		m.activeLayer = len(m.layers) - 1
+		return m, nil
+	case layerSelectMsg:
+		if msg.index >= 0 && msg.index < len(m.layers) {
+			m.activeLayer = msg.index
+		}
+		return m, nil
+	case layerMoveMsg:
+		m.moveLayer(msg.dx, msg.dy, msg.wrap)
+		return m, nil
+	case scrollMsg:
+		m.scroll(msg.dx, msg.dy)
+		return m, nil
+	case moveModeMsg:
+		m.moveMode = !m.moveMode
+		return m, nil
+	case layerVisibilityMsg:
+		if msg.index >= 0 && msg.index < len(m.layers) {
+			m.pushHistory()
+			m.layers[msg.index].Visible = msg.visible
+		}
+		return m, nil
+	case gridMsg:
+		m.gridSize, m.gridRune = msg.size, msg.r
+		return m, nil
+	case tabWidthMsg:
+		m.tabWidth = msg.width
+		return m, nil
+	case paletteToggledMsg:
+		m.paletteVisible = !m.paletteVisible
+		return m, nil
+	case minimapToggledMsg:
+		m.minimapVisible = !m.minimapVisible
+		if _, _, ok := m.minimapLayout(); m.minimapVisible && !ok {
+			return m, m.setStatus("the minimap shows when the canvas doesn't fit the view", false)
+		}
+		return m, nil
+	case transformMsg:
+		if err := m.transform(msg.name); err != nil {
+			return m, m.setStatus(err.Error(), true)
+		}
+		return m, nil
+	case replaceMsg:
+		n := m.replace(msg.from, msg.to)
+		if n == 0 {
+			return m, m.setStatus(fmt.Sprintf("no %c to replace", msg.from), true)
+		}
+		return m, m.setStatus(fmt.Sprintf("replaced %d %c with %c", n, msg.from, msg.to), false)
+	case fillSelectionMsg:
+		if !m.hasSelection {
+			return m, m.setStatus("nothing selected", true)
+		}
+		m.pushHistory()
+		s := m.selection.normalized()
+		m.canvas().Rect(s.x0, s.y0, s.x1, s.y1, m.brushPrimary, true)
+		return m, nil
+	case colorsToggledMsg:
+		if m.colorsVisible == msg.count {
+			m.colorsVisible = 0
+		} else {
+			m.colorsVisible = msg.count
+		}
+		return m, nil
+	case clearMsg:
+		m.pushHistory()
+		fill := transparent
+		if m.activeLayer == 0 {
+			fill = m.brushSecondary
+		}
+		m.canvas().Rect(0, 0, m.width - 1, m.height - 1, fill, true)
+		return m, nil
+	case newCanvasMsg:
+		m.pushHistory()
+		m.width, m.height = msg.width, msg.height
+		m.layers = blankLayers(msg.width, msg.height)
+		m.activeLayer = 0
+		return m, nil
+	case spliceMsg:
+		if err := m.splice(msg); err != nil {
+			return m, m.setStatus(err.Error(), true)
+		}
+		return m, nil
+	case resizeMsg:
+		if m.resizeAnchored(msg.width, msg.height, msg.anchor) {
+			return m, m.setStatus("resize discarded some content, use :undo to restore it", true)
+		}
+		return m, nil
+	case stampMsg:
+		m.stampBrush = msg.snippet
+		m.tool = toolStamp
+		m.anchorSet = false
+		m.textActive = false
+		return m, nil
+	case gradientArmedMsg:
+		m.gradientDirection = msg.direction
+		if msg.ramp != nil {
+			m.gradientRamp = msg.ramp
+		}
+		m.tool = toolGradient
+		m.anchorSet = false
+		m.textActive = false
+		return m, nil
+	case sprayArmedMsg:
+		//
+		// Without settings, spray the way it did last time.
+		//
+		if msg.density > 0 {
+			m.sprayRadius, m.sprayDensity = msg.radius, msg.density
+		}
+		m.tool = toolSpray
+		m.anchorSet = false
+		m.textActive = false
+		return m, nil
+	case sprayTickMsg:
+		if msg.id != m.sprayID || !m.spraying || m.tool != toolSpray {
+			return m, nil
+		}
+		m.sprayBurst()
+		return m, sprayTick(msg.id)
+	case eraseToggledMsg:
+		m.erasing = !m.erasing
+		return m, nil
+	case trimMsg:
+		if !m.trim() {
+			return m, m.setStatus("nothing to trim to, the canvas is blank", true)
+		}
+		return m, m.setStatus(fmt.Sprintf("trimmed to %dx%d", m.width, m.height), false)
+	case transparentBlanksMsg:
+		m.transparentBlanks = msg.on
+		return m, nil
+	case polyArmedMsg:
+		m.tool = toolPoly
+		m.polyClosed = msg.closed
+		m.anchorSet = false
+		m.textActive = false
+		return m, nil
+	case toolArmedMsg:
+		if msg.tool == toolPaste && m.clipboard == nil {
+			return m, nil
+		}
+		m.tool = msg.tool
+		m.anchorSet = false
+		m.textActive = false
+		return m, nil
+	case copyMsg:
+		if !m.hasSelection {
+			return m, nil
+		}
+		m.clipboard = extractRegion(m.canvas(), m.selection)
+		if msg.cut {
+			//
+			// The secondary brush doubles as the background.
+			//
+			m.pushHistory()
+			s := m.selection
+			m.canvas().Rect(s.x0, s.y0, s.x1, s.y1, m.brushSecondary, true)
+		}
+		return m, nil
+	case tea.WindowSizeMsg:
+		//
+		// The canvas stays in the top-left corner.  Whatever was drawn
+		// outside the new size has to be cleared away.
+		//
+		m.termWidth, m.termHeight = msg.Width, msg.Height
+		if m.fit {
+			//
+			// Nothing's been drawn yet, so there's nothing to resize.
+			//
+			m.fit = false
+			m.width, m.height = fitSize(msg.Width, msg.Height)
+			m.layers = blankLayers(m.width, m.height)
+		}
+		if m.cursorVisible {
+			m.scrollTo(m.cursorX, m.cursorY)
+		}
+		return m, tea.ClearScreen
+	case recordMsg:
+		return m, m.record(msg.register)
+	case playMsg:
+		return m.play(msg.register, msg.count)
+	case markMsg:
+		m.setMark(msg.name)
+		return m, m.setStatus(fmt.Sprintf("marked %s at %d,%d", msg.name, m.cursorX, m.cursorY), false)
+	case gotoMsg:
+		m.cursorVisible = true
+		m.cursorX, m.cursorY = clamp(msg.x, 0, m.width - 1), clamp(msg.y, 0, m.height - 1)
+		m.centerOn(m.cursorX, m.cursorY)
 		return m, nil
 	case tea.MouseMsg:
-		log.Printf("msg action=%q button=%q", msg.Action, msg.Button)
+		debugf("mouse %s", msg)
+		//
+		// The palette is drawn over the terminal, the canvas may be panned.
+		//
+		x, y, onCanvas := m.screenToCanvas(msg.X, msg.Y)
+		onCanvas = onCanvas && m.inCanvas(x, y)
+		m.mouseX, m.mouseY = x, y
+		m.shiftHeld = msg.Shift
 		switch msg.Action {
 		case tea.MouseActionPress:
-			log.Printf("X=%d Y=%d", msg.X, msg.Y)
-			if msg.X < m.width && msg.Y < m.height {
-				m.canvas[msg.Y][msg.X] = m.brush
+			debugf("X=%d Y=%d", msg.X, msg.Y)
+			if tea.MouseEvent(msg).IsWheel() {
+				if onCanvas {
+					m.wheel(msg)
+				}
 				return m, nil
 			}
+			brush, ok := m.mouseBrush(msg.Button)
+			if c, onColors := colorGridAt(m.colorsVisible, msg.X, msg.Y); ok && onColors {
+				return m, func() tea.Msg {
+					return colorChangedMsg{c}
+				}
+			} else if glyph, onPalette := paletteAt(m.palette, msg.X, msg.Y); ok && m.paletteVisible && onPalette {
+				slot := 1
+				if msg.Button == tea.MouseButtonRight {
+					slot = 2
+				}
+				return m, func() tea.Msg {
+					return brushChangedMsg{brush.WithRune(glyph), slot}
+				}
+			} else if m.paletteVisible && paletteCovers(m.palette, msg.X, msg.Y) {
+				return m, nil
+			} else if m.minimapClick(msg.X, msg.Y) {
+				return m, nil
+			} else if ok && onCanvas {
+				return m, m.click(x, y, msg.Button)
+			}
 		case tea.MouseActionMotion:
-			log.Printf("X=%d Y=%d", msg.X, msg.Y)
-			if msg.Button == tea.MouseButtonLeft && msg.X < m.width && msg.Y < m.height {
-				m.canvas[msg.Y][msg.X] = m.brush
+			debugf("X=%d Y=%d", msg.X, msg.Y)
+			brush, ok := m.mouseBrush(msg.Button)
+			if ok && onCanvas {
+				m.drag(x, y, brush)
 				return m, nil
 			}
+		case tea.MouseActionRelease:
+			m.release()
+			return m, nil
 		}
 	case tea.KeyMsg:
+		m.recordKey(msg)
+		if !m.commandActive && m.tool == toolText && m.textActive {
+			m.typeText(msg)
+			return m, nil
+		}
+
+		if m.commandActive && msg.String() == "tab" {
+			m.tabComplete()
+			return m, nil
+		} else if m.commandActive {
+			m.completions = nil
+		}
+
 		if m.commandActive && msg.String() == "enter" {
 			cmd := m.commandBuffer
 			m.commandBuffer = ""
 			m.commandActive = false
-			return m, interpretCmd(m, cmd)
+			if cmd != "" {
+				m.lastCommand = cmd
+				m.recordCommand(cmd)
+			}
+			return m, tea.Batch(interpretCmd(m, cmd), m.rememberCommand(cmd))
+		} else if m.commandActive && msg.String() == "up" {
+			m.recallCommand(-1)
+			return m, nil
+		} else if m.commandActive && msg.String() == "down" {
+			m.recallCommand(1)
+			return m, nil
 		} else if m.commandActive && msg.String() == "backspace" {
-			m.commandBuffer = m.commandBuffer[:len(m.commandBuffer)-1]
+			//
+			// Like in vim, backspacing past the start leaves command mode.
+			//
+			if m.commandBuffer == "" {
+				m.commandActive = false
+				return m, nil
+			}
+			_, size := utf8.DecodeLastRuneInString(m.commandBuffer)
+			m.commandBuffer = m.commandBuffer[:len(m.commandBuffer)-size]
+			return m, nil
+		} else if m.commandActive && msg.String() == "esc" {
+			m.commandBuffer = ""
+			m.commandActive = false
 			return m, nil
 		} else if m.commandActive && msg.String() == "ctrl+c" {
 			m.commandActive = false
@@ -113,171 +801,635 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.commandBuffer += msg.String()
 			return m, nil
 		}
-		switch msg.String() {
+		if msg.Paste {
+			if err := m.pour(string(msg.Runes)); err != nil {
+				return m, m.setStatus(err.Error(), true)
+			}
+			return m, nil
+		}
+		switch m.keys.action(msg.String()) {
 
-		case ":":
+		case actionCommand:
 			m.commandActive = true
+			m.commandHistoryCursor = len(m.commandHistory)
+			m.status = ""
+			return m, nil
+
+		case actionQuit:
+			return m.quit(false)
+
+		case actionRepeat:
+			//
+			// Commands that change the canvas make their own undo steps,
+			// so each repetition can be undone separately.
+			//
+			if m.lastCommand == "" {
+				return m, m.setStatus("no command to repeat", true)
+			}
+			return m, interpretCmd(m, m.lastCommand)
+
+		case actionCancel:
+			m.cancelTool()
+			return m, nil
+
+		case actionLeft, actionRight, actionUp, actionDown:
+			dx, dy := arrowDelta(m.keys.action(msg.String()))
+			if m.moveMode {
+				m.moveLayer(dx, dy, false)
+			} else {
+				m.moveCursor(dx, dy)
+			}
+			return m, nil
+
+		case actionPanLeft, actionPanRight, actionPanUp, actionPanDown:
+			dx, dy := arrowDelta(strings.TrimPrefix(m.keys.action(msg.String()), "pan-"))
+			m.pan(dx * panStep, dy * panStep)
+			return m, nil
+
+		case actionPaint:
+			if m.tool == toolPoly {
+				m.endPoly(m.brushPrimary)
+				return m, nil
+			}
+			m.cursorVisible = true
+			m.pushHistory()
+			brush := m.brushPrimary
+			if m.erasing {
+				brush = m.eraser()
+			}
+			m.stamp(m.cursorX, m.cursorY, brush)
 			return m, nil
 
-		case "ctrl+c", "q":
-			return m, tea.Quit
+		case actionErase:
+			m.erasing = !m.erasing
+			return m, nil
+
+		case actionUndo:
+			m.undo()
+			return m, nil
+
+		case actionRedo:
+			m.redo()
+			return m, nil
+
+		case actionPick:
+			m.tool = toolPick
+			return m, nil
+
+		case actionPour:
+			return m, readClipboard
+
+		case actionLighter, actionDarker:
+			delta := 1
+			if m.keys.action(msg.String()) == actionLighter {
+				delta = -1
+			}
+			m.cycleShade(delta)
+			return m, nil
+
+		case actionSwap:
+			m.brushPrimary, m.brushSecondary = m.brushSecondary, m.brushPrimary
+			return m, nil
+
+		case actionRecent:
+			if !m.cycleRecentBrush() {
+				return m, m.setStatus("no other recent brushes, see :brushes", true)
+			}
+			return m, nil
+
+		case actionSmaller:
+			m.resizeBrush(-1)
+			return m, nil
+
+		case actionBigger:
+			m.resizeBrush(1)
+			return m, nil
 
 		default:
-			m.brush = pixel(msg.String()[0])
+			m.brushPrimary.R = []rune(msg.String())[0]
 			return m, nil
 		}
 	}
 	return m, nil
 }
 
+//
+// Refuse to quit with unsaved changes unless forced.
+//
+func (m model) quit(force bool) (tea.Model, tea.Cmd) {
+	if force {
+		return m, tea.Quit
+	}
+	//
+	// Show the tab with the unsaved changes, so that :w saves them.
+	//
+	index, dirty := m.dirtyTab()
+	if !dirty {
+		return m, tea.Quit
+	}
+	m.switchTab(index)
+	if m.filename != "" {
+		return m, m.setStatus(fmt.Sprintf("unsaved changes to %s, use :w to save or :q! to force quit", m.filename), true)
+	}
+	return m, m.setStatus("unsaved changes, use :q! to force quit", true)
+}
+
+//
+// A summary of the editor state, e.g. "12,7  # U+0023  80x50  [+]".
+//
+func (m model) statusBar() string {
+	modified := ""
+	if m.dirty {
+		modified = "  [+]"
+	}
+	brush := fmt.Sprintf("%c U+%04X", m.brushPrimary.R, m.brushPrimary.R)
+	if m.erasing {
+		brush = "eraser"
+	}
+	dangling := ""
+	if m.showDangling {
+		n := 0
+		eachDangling(canvas.Composite(m.layers, m.width, m.height), func(x, y int) { n++ })
+		dangling = fmt.Sprintf("  %d dangling", n)
+	}
+	recording := ""
+	if m.recording != "" {
+		recording = "  recording @" + m.recording
+	}
+	return fmt.Sprintf(
+		"%d,%d  %s  %dx%d%s%s%s",
+		m.mouseX, m.mouseY, brush, m.width, m.height, dangling, modified, recording,
+	)
+}
+
+const statusTimeout = 3 * time.Second
+
+func (m *model) setStatus(text string, isErr bool) tea.Cmd {
+	m.statusID++
+	m.status, m.statusErr = text, isErr
+	if m.headless {
+		return nil
+	}
+	id := m.statusID
+	return tea.Tick(statusTimeout, func(time.Time) tea.Msg {
+		return statusClearedMsg{id}
+	})
+}
+
 func (m model) View() string {
 	var buffer bytes.Buffer
 
-	if err := dumpCanvas(m.canvas, m.width, m.height, &buffer); err != nil {
-		log.Printf("err: %q", err)
+	canvas := m.preview()
+	if m.showDangling {
+		markDangling(canvas)
+	}
+	minimap := m.minimap(canvas)
+	drawGrid(canvas, m.gridSize, m.gridRune)
+	canvas = m.viewWindow(canvas)
+	m.drawMinimap(canvas, minimap)
+	if m.paletteVisible {
+		drawPalette(canvas, m.palette)
+	}
+	if m.colorsVisible > 0 {
+		drawColorGrid(canvas, m.colorsVisible)
+	}
+	if err := m.dumpCanvasWithCursor(canvas, &buffer); err != nil {
+		errorf("rendering: %v", err)
+	}
+	if tabs := m.tabBar(); tabs != "" {
+		fmt.Fprintf(&buffer, "%s\n", m.fitLine(tabs))
+	}
+	fmt.Fprintf(&buffer, "%s\n", m.fitLine(m.statusBar()))
+	if m.commandActive && len(m.completions) > 1 {
+		fmt.Fprintf(&buffer, "%s\n", m.fitLine(strings.Join(m.completions, "  ")))
 	}
 	if m.commandActive {
-		fmt.Fprintf(&buffer, ":%s█\n", m.commandBuffer)
+		fmt.Fprintf(&buffer, "%s\n", m.fitCommandLine(":" + m.commandBuffer + "█" + brushPreview(m.commandBuffer)))
+	} else if m.status != "" && m.statusErr {
+		fmt.Fprintf(&buffer, "\x1b[%sm%s\x1b[0m\n", color("1").SGR(), m.fitLine(m.status))
+	} else if m.status != "" {
+		fmt.Fprintf(&buffer, "%s\n", m.fitLine(m.status))
 	}
 	return buffer.String()
 }
 
-type quitMsg struct {}
+type quitMsg struct {
+	force bool
+}
+
+type errMsg struct {
+	err error
+}
+
+type statusMsg struct {
+	text string
+}
+
+type statusClearedMsg struct {
+	id int
+}
+
+type savedMsg struct {
+	filename string
+}
 
 type canvasLoadedMsg struct {
 	width int
 	height int
-	canvas [][]pixel
+	layers []layer
+	filename string
+	marks map[string]point
+}
+
+type newFileMsg struct {
+	filename string
 }
 
 type brushChangedMsg struct {
 	brush pixel
+	slot int
 }
 
-func interpretCmd(m model, command string) tea.Cmd {
-	return func() tea.Msg {
-		if command == "q" || command == "quit" {
-			return quitMsg{}
-		}
+type bgChangedMsg struct {
+	color color
+}
 
-		split := strings.SplitN(command, " ", 2)
-		verb := split[0]
-		rest := split[1]
+type attrChangedMsg struct {
+	attr attrs
+	on bool
+}
 
-		switch verb {
-		case "q", "quit":
-			return quitMsg{}
+type colorChangedMsg struct {
+	color color
+}
 
-		case "s", "save":
-			fout, err := os.OpenFile(rest, os.O_WRONLY | os.O_CREATE, 0o644)
-			if err != nil {
-				log.Printf("err: %q", err)
-				return nil
-			}
-			fmt.Fprintf(fout, "%d %d\n", m.width, m.height)
-			defer fout.Close()
+type brushSizeChangedMsg struct {
+	size int
+}
 
-			if err := dumpCanvas(m.canvas, m.width, m.height, fout); err != nil {
-				log.Printf("err: %q", err)
-				return nil
-			}
+type brushShapeChangedMsg struct {
+	shape brushShape
+}
 
-		case "l", "load":
-			fin, err := os.Open(rest)
-			if err != nil {
-				log.Printf("err: %q", err)
-				return nil
-			}
-			defer fin.Close()
+type autoConnectChangedMsg struct {
+	autoConnect bool
+}
 
-			width, height, canvas, err := loadCanvas(fin)
-			if err != nil {
-				log.Printf("err: %q", err)
-				return nil
-			}
+type stampMsg struct {
+	snippet [][]pixel
+}
 
-			return canvasLoadedMsg{width, height, canvas}
+type eraseToggledMsg struct {}
 
-		case "b", "brush":
-			rest = strings.ToLower(rest)
-			if strings.HasPrefix(rest, "\\u") || strings.HasPrefix(rest, "u+") {
-				if codePoint, err := strconv.ParseInt(rest[2:], 16, 64); err == nil {
-					return brushChangedMsg{pixel(codePoint)}
-				}
-			}
-			return brushChangedMsg{pixel(rest[0])}
-		}
-		return nil
-	}
+type gradientArmedMsg struct {
+	direction gradientDirection
+	ramp []rune
 }
 
-func loadCanvas(fin io.Reader) (width, height int, canvas [][]pixel, err error) {
-	reader := bufio.NewReader(fin)
-	firstLine, err := reader.ReadBytes('\n')
+type transparentBlanksMsg struct {
+	on bool
+}
+
+type wrapChangedMsg struct {
+	wrap bool
+}
+
+type trimSafeChangedMsg struct {
+	on bool
+}
+
+type snapChangedMsg struct {
+	snap bool
+}
+
+type inkChangedMsg struct {
+	opacity float64
+}
+
+type danglingChangedMsg struct {
+	on bool
+}
+
+type smoothChangedMsg struct {
+	smooth bool
+}
+
+type orthoChangedMsg struct {
+	ortho bool
+}
+
+type mirrorChangedMsg struct {
+	mode mirrorMode
+}
+
+type fillArmedMsg struct {
+	diagonal bool
+}
+
+type toolArmedMsg struct {
+	tool tool
+}
+
+type borderMsg struct {
+	glyphs borderGlyphs
+}
+
+type layerNewMsg struct {}
+
+type layerSelectMsg struct {
+	index int
+}
+
+type layerVisibilityMsg struct {
+	index int
+	visible bool
+}
+
+type layerMoveMsg struct {
+	dx, dy int
+	wrap bool
+}
+
+type scrollMsg struct {
+	dx, dy int
+}
+
+type moveModeMsg struct {}
+
+type copyMsg struct {
+	cut bool
+}
+
+type gridMsg struct {
+	size int
+	r rune
+}
+
+type tabWidthMsg struct {
+	width int
+}
+
+type paletteToggledMsg struct {}
+
+type fillSelectionMsg struct {}
+
+type replaceMsg struct {
+	from, to rune
+}
+
+type transformMsg struct {
+	name string
+}
+
+type colorsToggledMsg struct {
+	count int
+}
+
+type clearMsg struct {}
+
+type trimMsg struct {}
+
+type newCanvasMsg struct {
+	width, height int
+}
+
+type resizeMsg struct {
+	width, height int
+	anchor anchor
+}
+
+type gotoMsg struct {
+	x, y int
+}
+
+type undoMsg struct {}
+
+type redoMsg struct {}
+
+//
+// Read a file saved by gopnik, returning either a canvasLoadedMsg or an
+// errMsg.
+//
+func loadFile(filename string) tea.Msg {
+	fin, err := os.Open(filename)
 	if err != nil {
-		return 0, 0, nil, err
-	}
-	split := strings.SplitN(strings.TrimRight(string(firstLine), " \n"), " ", 2)
-	if width, err = strconv.Atoi(split[0]); err != nil {
-		return 0, 0, nil, err
+		return errMsg{err}
 	}
-	if height, err = strconv.Atoi(split[1]); err != nil {
-		return 0, 0, nil, err
+	defer fin.Close()
+
+	h, layers, err := canvas.Load(fin)
+	if err != nil {
+		return errMsg{fmt.Errorf("%s: %w", filename, err)}
 	}
 
-	canvas = make([][]pixel, height)
+	return canvasLoadedMsg{h.Width, h.Height, layers, filename, h.Marks}
+}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			r, _, err := reader.ReadRune()
-			if err != nil {
-				return 0, 0, nil, err
-			}
-			canvas[y] = append(canvas[y], pixel(r))
-		}
+//
+// Open whatever filename is: a gopnik file, a plain text file to import,
+// or nothing at all yet, in which case it becomes the name to save to.
+//
+func openFile(filename string, tabWidth int) tea.Msg {
+	fin, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return newFileMsg{filename}
+	} else if err != nil {
+		return errMsg{err}
+	}
+	defer fin.Close()
+
+	h, layers, isGopnik, err := readArt(fin, tabWidth)
+	if err == errEmpty {
+		return newFileMsg{filename}
+	} else if err != nil {
+		return errMsg{fmt.Errorf("%s: %w", filename, err)}
+	} else if !isGopnik {
 		//
-		// Read EOL
+		// As with :import, saving shouldn't overwrite the text file.
 		//
-		if _, err := reader.ReadBytes('\n'); err != nil {
-			return 0, 0, nil, err
-		}
+		return canvasLoadedMsg{h.Width, h.Height, layers, "", nil}
 	}
-
-	return width, height, canvas, nil
+	return canvasLoadedMsg{h.Width, h.Height, layers, filename, h.Marks}
 }
 
-func dumpCanvas(canvas [][]pixel, width, height int, fout io.Writer) error {
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			if _, err := fmt.Fprintf(fout, string(canvas[y][x])); err != nil {
-				return err
-			}
-		}
-		if _, err := fmt.Fprintf(fout, "\n"); err != nil {
-			return err
-		}
+var errEmpty = errors.New("empty file")
+
+//
+// Read either a gopnik file or plain text, deciding by the first line,
+// which is all a gopnik header needs.  Empty text is errEmpty.  Text gets
+// a header of its own, with just its size.
+//
+func readArt(fin io.Reader, tabWidth int) (h header, layers []layer, isGopnik bool, err error) {
+	reader := bufio.NewReader(fin)
+	firstLine, _ := reader.Peek(reader.Size())
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if canvas.IsHeader(string(firstLine)) {
+		h, layers, err = canvas.Load(reader)
+		return h, layers, true, err
+	}
+
+	canvas, err := importText(reader, tabWidth)
+	if err != nil {
+		return h, nil, false, err
+	}
+	h.Width, h.Height = canvas.Bounds()
+	h.Layers = 1
+	if h.Width == 0 || h.Height == 0 {
+		return h, nil, false, errEmpty
 	}
-	return nil
+	return h, []layer{{Grid: canvas, Visible: true}}, false, nil
+}
+
+//
+// Write the cells of a row, without a newline.
+//
+func dumpRow(row []pixel, fout io.Writer) error {
+	_, err := io.WriteString(fout, canvas.FormatRow(row))
+	return err
 }
 
 func main() {
-	logpath := filepath.Join(os.TempDir(), "gopnik.log")
-	log.Printf("redirecting stderr to %s", logpath)
-	f, err := tea.LogToFile(logpath, "debug")
+	width := flag.Int("width", defaultWidth, "width of the initial canvas")
+	height := flag.Int("height", defaultHeight, "height of the initial canvas")
+	load := flag.String("load", "", "open `file` on start")
+	logPath := flag.String("log", "", "append log messages to `file`")
+	debug := flag.Bool("debug", false, "log every message, not just errors")
+	script := flag.String("script", "", "run the commands in `file` without the editor, then exit")
+	fit := flag.Bool("fit", false, "size the initial canvas to the terminal")
+	output := flag.String("o", "", "without the editor, write the canvas to `file` (or - for stdout) and exit")
+	sessionPath := flag.String("session", "", "restore the tabs and settings saved to `file` by :mksession")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file|-]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 || (flag.NArg() == 1 && *load != "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	//
+	// Nothing may go to stderr while the program owns the terminal, so
+	// without a log file the log goes nowhere.
+	//
+	if *logPath == "" {
+		log.SetOutput(io.Discard)
+	} else {
+		f, err := tea.LogToFile(*logPath, "gopnik")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gopnik: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+	}
+	if *debug {
+		logThreshold = levelDebug
+	}
+	infof("starting")
+
+	//
+	// Flags given on the command line take precedence over the config.
+	//
+	cfg := loadConfig()
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if *fit && (set["width"] || set["height"]) {
+		fmt.Fprintln(os.Stderr, "gopnik: -fit and -width or -height don't go together")
+		os.Exit(2)
+	}
+	if *sessionPath != "" && (flag.NArg() > 0 || *load != "" || *fit || set["width"] || set["height"]) {
+		fmt.Fprintln(os.Stderr, "gopnik: -session doesn't go with a file, -load, -fit, -width or -height")
+		os.Exit(2)
+	}
+	if !set["width"] {
+		*width = cfg.width
+	}
+	if !set["height"] {
+		*height = cfg.height
+	}
+	if err := (header{Width: *width, Height: *height, Layers: 1}).Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "gopnik: %v\n", err)
+		os.Exit(2)
+	}
+	palette := cfg.palette
+	if palette == nil {
+		palette = loadPalette()
+	}
+	keys, err := newKeymap(cfg.keys)
 	if err != nil {
-		log.Fatal(err)
+		warningf("ignoring key bindings: %v", err)
+		keys, _ = newKeymap(nil)
 	}
-	defer f.Close()
 
 	m := model{
-		width: 80,
-		height: 50,
-		canvas: newCanvas(80, 50),
-		brush: '#',
+		document: newDocument(*width, *height, cfg.brushPrimary, cfg.brushSecondary),
+		brushSize: cfg.brushSize,
+		brushShape: cfg.brushShape,
+		historyDepth: cfg.historyDepth,
+		palette: palette,
+		commandHistory: loadCommandHistory(),
+		gridSize: cfg.gridSize,
+		gridRune: cfg.gridRune,
+		gradientRamp: defaultRamp,
+		shadeRamp: cfg.shadeRamp,
+		tabWidth: defaultTabWidth,
+		startupFile: *load,
+		keys: keys,
+		rows: &rowCache{},
+		sprayRadius: 3,
+		sprayDensity: 4,
+		autosaveInterval: time.Duration(cfg.autosave) * time.Second,
+	}
+	if flag.NArg() == 1 && flag.Arg(0) == stdioName {
+		width, height, layers, ok, err := readStdin(m.tabWidth)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gopnik: stdin: %v\n", err)
+			os.Exit(1)
+		} else if ok {
+			m.width, m.height, m.layers = width, height, layers
+			normalizeLayers(m.layers, m.width, m.height)
+		}
+		m.filename = stdioName
+	} else if flag.NArg() == 1 {
+		m.startupFile, m.startupSniff = flag.Arg(0), true
+	}
+	m.fit = *fit && m.startupFile == "" && m.filename == ""
+	if *sessionPath != "" {
+		if m, err = restoreSession(m, *sessionPath); err != nil {
+			fmt.Fprintf(os.Stderr, "gopnik: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *script != "" || *output != "" {
+		final, err := runHeadless(m, *script, *output)
+		if err != nil {
+			errorf("%v", err)
+			fmt.Fprintf(os.Stderr, "gopnik: %v\n", err)
+			os.Exit(1)
+		}
+		if final.stdout != nil {
+			os.Stdout.Write(final.stdout)
+		}
+		return
 	}
 
-	program := tea.NewProgram(m)
-	if _, err := program.Run(); err != nil {
-		log.Fatal(err)
+	options, err := terminalOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gopnik: %v\n", err)
+		os.Exit(1)
+	}
+	program := tea.NewProgram(m, options...)
+	final, err := program.Run()
+	if err != nil {
+		errorf("%v", err)
+		fmt.Fprintf(os.Stderr, "gopnik: %v\n", err)
+		os.Exit(1)
+	}
+	if final, ok := final.(model); ok && final.stdout != nil {
+		os.Stdout.Write(final.stdout)
 	}
 }
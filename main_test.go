@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func TestNormalizeLayers(t *testing.T) {
+	layers := []layer{
+		{Grid: canvas.Of([][]pixel{{{R: 'a'}}}, 1, 1, transparent), Visible: true},
+		{Grid: canvas.Of([][]pixel{{{R: 'b'}, {R: 'c'}, {R: 'd'}}, {}, {}}, 3, 3, transparent), Visible: true},
+	}
+	normalizeLayers(layers, 2, 2)
+	if got, want := regionRows(layers[0].Grid.Cells()), []string{"a ", "  "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bottom layer: got %q, want %q", got, want)
+	}
+	if got, want := regionRows(layers[1].Grid.Cells()), []string{"bc", ".."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top layer: got %q, want %q", got, want)
+	}
+}
+
+func TestDumpCanvasWithCursor(t *testing.T) {
+	c := canvasOf("%s%d%%", "100%  ")
+	c.Set(4, 1, pixel{R: '%', FG: "2"})
+	var b strings.Builder
+	m := model{document: document{width: 6, height: 2, cursorX: 3, cursorY: 1, cursorVisible: true}}
+	if err := m.dumpCanvasWithCursor(c, &b); err != nil {
+		t.Fatal(err)
+	}
+	if want := "%s%d%%\n100\x1b[7m%\x1b[27m\x1b[38;5;2m%\x1b[39m \n"; b.String() != want {
+		t.Errorf("dumped %q, want %q", b.String(), want)
+	}
+}
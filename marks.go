@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//
+// Remember the cursor position as name, for :goto name.
+//
+type markMsg struct {
+	name string
+}
+
+func markNames(marks map[string]point) []string {
+	var names []string
+	for name := range marks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//
+// Marks are shared with the copies of the model that undo and the tabs
+// hold on to, so setting one makes a new map rather than changing the old
+// one.  Setting a mark doesn't count as a change to the canvas, though it
+// does get saved.
+//
+func (m *model) setMark(name string) {
+	marks := maps.Clone(m.marks)
+	if marks == nil {
+		marks = map[string]point{}
+	}
+	marks[name] = point{X: m.cursorX, Y: m.cursorY}
+	m.marks = marks
+}
+
+//
+// With a name, mark the cursor position.  Without one, list the marks,
+// e.g. "a 3,4  b 10,2".
+//
+func markCommand(m model, arg string) tea.Msg {
+	if strings.ContainsAny(arg, " \t") {
+		return errMsg{errUsage}
+	}
+	return markMsg{arg}
+}
+
+func listMarks(m model) tea.Msg {
+	if len(m.marks) == 0 {
+		return statusMsg{"no marks, see :mark"}
+	}
+	var marks []string
+	for _, name := range markNames(m.marks) {
+		marks = append(marks, fmt.Sprintf("%s %d,%d", name, m.marks[name].X, m.marks[name].Y))
+	}
+	return statusMsg{strings.Join(marks, "  ")}
+}
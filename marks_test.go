@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestMarks(t *testing.T) {
+	m := run(t, testModel(4, 3), "goto 2 1", "mark a", "goto 3 2", "mark b", "goto 0 0")
+	if got, want := listMarks(m), (statusMsg{"a 2,1  b 3,2"}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	m = run(t, m, "goto a")
+	if m.cursorX != 2 || m.cursorY != 1 {
+		t.Errorf("went to %d,%d, want 2,1", m.cursorX, m.cursorY)
+	}
+
+	//
+	// The copy held by undo keeps the marks it had.
+	//
+	before := m.marks
+	m = run(t, m, "goto 0 0", "mark a")
+	if before["a"] != (point{X: 2, Y: 1}) || len(before) != 2 {
+		t.Errorf("setting a mark changed the old marks to %v", before)
+	}
+
+	if _, err := m.apply(interpretCmd(m, "goto c")()); err == nil || err.Error() != "no mark \"c\", see :mark" {
+		t.Errorf(":goto c: got %v", err)
+	}
+}
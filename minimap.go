@@ -0,0 +1,108 @@
+package main
+
+//
+// The most cells the minimap takes up in the top-right corner of the view.
+// It shrinks the canvas by the same factor both ways, so that it has the
+// canvas's shape.
+//
+const (
+	minimapMaxWidth = 24
+	minimapMaxHeight = 8
+)
+
+type minimapToggledMsg struct {}
+
+//
+// Which blocks of block x block cells of c have anything in them other
+// than blanks, a row of blocks at a time.  The blocks along the right and
+// bottom edges are cut short when the size of c isn't a multiple of block.
+//
+func minimapBlocks(c Canvas, block int) [][]bool {
+	width, height := c.Bounds()
+	blocks := make([][]bool, (height + block - 1) / block)
+	for by := range blocks {
+		blocks[by] = make([]bool, (width + block - 1) / block)
+	}
+	for y, row := range c.Cells() {
+		for x, p := range row {
+			if !p.IsBlank() {
+				blocks[y / block][x / block] = true
+			}
+		}
+	}
+	return blocks
+}
+
+//
+// Where the minimap's left edge is in the view, and how many canvas cells
+// each of its cells stands for.  It's only shown when the canvas doesn't
+// fit in the view, and the view has room for it.
+//
+func (m model) minimapLayout() (left, block int, ok bool) {
+	viewWidth, viewHeight := m.viewSize()
+	if !m.minimapVisible || (viewWidth >= m.width && viewHeight >= m.height) {
+		return 0, 0, false
+	}
+	block = max((m.width + minimapMaxWidth - 1) / minimapMaxWidth, (m.height + minimapMaxHeight - 1) / minimapMaxHeight)
+	width, height := (m.width + block - 1) / block, (m.height + block - 1) / block
+	if width * 2 > viewWidth || height * 2 > viewHeight {
+		return 0, 0, false
+	}
+	return viewWidth - width, block, true
+}
+
+//
+// The blocks of c for the minimap, or nil if it isn't shown.
+//
+func (m model) minimap(c Canvas) [][]bool {
+	if _, block, ok := m.minimapLayout(); ok {
+		return minimapBlocks(c, block)
+	}
+	return nil
+}
+
+//
+// Draw the minimap on window, the part of the canvas that's in view:
+// filled blocks as ▓ and empty ones as dots, with the blocks in view
+// highlighted.  A blank column and row keep it apart from the canvas
+// around it.
+//
+func (m model) drawMinimap(window Canvas, blocks [][]bool) {
+	left, block, ok := m.minimapLayout()
+	if !ok || blocks == nil {
+		return
+	}
+	viewWidth, viewHeight := m.viewSize()
+	originX, originY := m.viewOrigin()
+	window.Rect(left - 1, 0, left + len(blocks[0]) - 1, len(blocks), pixel{R: ' '}, true)
+	for by, row := range blocks {
+		for bx, filled := range row {
+			p := pixel{R: '·', FG: "8"}
+			if filled {
+				p = pixel{R: '▓'}
+			}
+			inX := bx >= originX / block && bx <= (originX + viewWidth - 1) / block
+			inY := by >= originY / block && by <= (originY + viewHeight - 1) / block
+			if inX && inY {
+				p.BG = "4"
+			}
+			window.Set(left + bx, by, p)
+		}
+	}
+}
+
+//
+// What a click at view position (x, y) does to the minimap: on a block,
+// it pans to center on it.  False if the click missed the minimap and its
+// margin, and should go to the canvas instead.
+//
+func (m *model) minimapClick(x, y int) bool {
+	left, block, ok := m.minimapLayout()
+	if !ok || x < left - 1 || y > (m.height + block - 1) / block {
+		return false
+	}
+	if x >= left && y < (m.height + block - 1) / block {
+		m.centerOn((x - left) * block + block / 2, y * block + block / 2)
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimapBlocks(t *testing.T) {
+	c := canvasOf(
+		"  .  ",
+		"   .x",
+		"漢   ",
+	)
+	c.Set(2, 0, pixel{R: ' ', BG: "1"})
+	tests := []struct {
+		block int
+		want [][]bool
+	}{
+		{1, [][]bool{
+			{false, false, true, false, false},
+			{false, false, false, false, true},
+			{true, false, false, false, false},
+		}},
+		{2, [][]bool{{false, true, true}, {true, false, false}}},
+		{5, [][]bool{{true}}},
+	}
+	for _, test := range tests {
+		if got := minimapBlocks(c, test.block); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("blocks of %d: got %v, want %v", test.block, got, test.want)
+		}
+	}
+}
+
+func TestMinimap(t *testing.T) {
+	m := testModel(96, 40)
+	m.termWidth, m.termHeight = 40, 23
+	m.layers[0].Grid.Set(90, 35, pixel{R: 'x'})
+	if _, _, ok := m.minimapLayout(); ok {
+		t.Errorf("shown before :minimap")
+	}
+	m = run(t, m, "minimap")
+	left, block, ok := m.minimapLayout()
+	if !ok || block != 5 || left != 20 {
+		t.Fatalf("got left %d, block %d, %v, want 20, 5, true", left, block, ok)
+	}
+
+	window := m.viewWindow(m.canvas())
+	m.drawMinimap(window, m.minimap(m.canvas()))
+	if p := window.At(left + 18, 7); p.R != '▓' || p.BG != noColor {
+		t.Errorf("the block with the x in it: got %+v", p)
+	}
+	if p := window.At(left, 0); p.R != '·' || p.BG != "4" {
+		t.Errorf("the block in view: got %+v", p)
+	}
+	if p := window.At(left - 1, 0); p != (pixel{R: ' '}) {
+		t.Errorf("the margin: got %+v", p)
+	}
+
+	if !m.minimapClick(left + 18, 7) {
+		t.Fatalf("missed the minimap")
+	}
+	if x, y := m.viewOrigin(); x != 56 || y != 20 {
+		t.Errorf("got the view at %d, %d, want 56, 20", x, y)
+	}
+	if m.minimapClick(0, 0) {
+		t.Errorf("took a click on the canvas")
+	}
+
+	m = testModel(10, 5)
+	m.termWidth, m.termHeight = 40, 23
+	m = run(t, m, "minimap")
+	if _, _, ok := m.minimapLayout(); ok {
+		t.Errorf("shown for a canvas that fits")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "fmt"
+
+type mirrorMode int
+
+const (
+	mirrorNone mirrorMode = iota
+	mirrorX
+	mirrorY
+	mirrorXY
+)
+
+func parseMirrorMode(s string) (mirrorMode, error) {
+	switch s {
+	case "none", "off":
+		return mirrorNone, nil
+	case "x":
+		return mirrorX, nil
+	case "y":
+		return mirrorY, nil
+	case "xy":
+		return mirrorXY, nil
+	}
+	return mirrorNone, fmt.Errorf("bad mirror mode %q: expected none, x, y or xy", s)
+}
+
+//
+// The point (x, y) plus its reflections about the center of the canvas.
+// mirrorX reflects across the vertical axis, mirrorY across the horizontal
+// one.  When a dimension is even, the axis falls between two cells, so
+// cell 0 pairs with cell width-1 either way.  span is how many columns the
+// glyph covers, so that a wide glyph's reflection covers the mirrored columns.
+//
+func mirrorPoints(x, y, width, height, span int, mode mirrorMode) [][2]int {
+	mx, my := width - span - x, height - 1 - y
+	var points [][2]int
+	switch mode {
+	case mirrorX:
+		points = [][2]int{{x, y}, {mx, y}}
+	case mirrorY:
+		points = [][2]int{{x, y}, {x, my}}
+	case mirrorXY:
+		points = [][2]int{{x, y}, {mx, y}, {x, my}, {mx, my}}
+	default:
+		return [][2]int{{x, y}}
+	}
+
+	//
+	// Points on an axis are their own reflection.
+	//
+	var unique [][2]int
+	seen := map[[2]int]bool{}
+	for _, p := range points {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
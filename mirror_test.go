@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMirrorPoints(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y, width, height, span int
+		mode mirrorMode
+		want [][2]int
+	}{
+		{"none", 1, 2, 10, 5, 1, mirrorNone, [][2]int{{1, 2}}},
+		{"x", 1, 2, 10, 5, 1, mirrorX, [][2]int{{1, 2}, {8, 2}}},
+		{"y", 1, 1, 10, 5, 1, mirrorY, [][2]int{{1, 1}, {1, 3}}},
+		{"xy", 0, 0, 4, 4, 1, mirrorXY, [][2]int{{0, 0}, {3, 0}, {0, 3}, {3, 3}}},
+		{"on the x axis", 2, 0, 5, 5, 1, mirrorX, [][2]int{{2, 0}}},
+		{"on both axes", 2, 2, 5, 5, 1, mirrorXY, [][2]int{{2, 2}}},
+		{"across an even axis", 4, 0, 10, 1, 1, mirrorX, [][2]int{{4, 0}, {5, 0}}},
+		{"wide", 0, 0, 10, 1, 2, mirrorX, [][2]int{{0, 0}, {8, 0}}},
+		{"wide on the axis", 4, 0, 10, 1, 2, mirrorX, [][2]int{{4, 0}}},
+	}
+	for _, test := range tests {
+		got := mirrorPoints(test.x, test.y, test.width, test.height, test.span, test.mode)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseMirrorMode(t *testing.T) {
+	for s, want := range map[string]mirrorMode{"off": mirrorNone, "none": mirrorNone, "x": mirrorX, "y": mirrorY, "xy": mirrorXY} {
+		if got, err := parseMirrorMode(s); err != nil || got != want {
+			t.Errorf("%q: got %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseMirrorMode("yx"); err == nil {
+		t.Errorf("yx: got no error")
+	}
+}
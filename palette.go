@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var defaultPalette = [][]rune{
+	[]rune("─│┌┐└┘├┤┬┴┼"),
+	[]rune("═║╔╗╚╝╠╣╦╩╬"),
+	[]rune("█▀▄▌▐▖▗▘▝"),
+	[]rune("░▒▓█"),
+	[]rune("←↑→↓↖↗↘↙"),
+	[]rune("•○●◆◇■□▲▼"),
+}
+
+//
+// The palette is drawn in the top-left corner, one group per row, with a
+// space on either side of each glyph so that they're easy to hit.
+//
+func paletteCellX(index int) int {
+	return 1 + 2 * index
+}
+
+func paletteWidth(palette [][]rune) int {
+	width := 0
+	for _, group := range palette {
+		if w := paletteCellX(len(group)); w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+func drawPalette(canvas Canvas, palette [][]rune) {
+	canvas.Rect(0, 0, paletteWidth(palette) - 1, len(palette) - 1, pixel{R: ' '}, true)
+	for y, group := range palette {
+		for i, r := range group {
+			canvas.Set(paletteCellX(i), y, pixel{R: r})
+		}
+	}
+}
+
+//
+// Whether (x, y) is covered by the palette, glyph or not.
+//
+func paletteCovers(palette [][]rune, x, y int) bool {
+	return y >= 0 && y < len(palette) && x >= 0 && x < paletteWidth(palette)
+}
+
+//
+// The glyph displayed at (x, y), if any.
+//
+func paletteAt(palette [][]rune, x, y int) (rune, bool) {
+	if y < 0 || y >= len(palette) || x < 1 || (x - 1) % 2 != 0 {
+		return 0, false
+	}
+	if i := (x - 1) / 2; i < len(palette[y]) {
+		return palette[y][i], true
+	}
+	return 0, false
+}
+
+//
+// Custom palettes have one group of glyphs per line.  Whitespace is
+// ignored, as are empty lines and lines starting with #.
+//
+func readPalette(fin io.Reader) ([][]rune, error) {
+	var palette [][]rune
+	scanner := bufio.NewScanner(fin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if group := []rune(strings.Join(strings.Fields(line), "")); len(group) > 0 {
+			palette = append(palette, group)
+		}
+	}
+	return palette, scanner.Err()
+}
+
+func palettePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopnik", "palette"), nil
+}
+
+//
+// The user's palette if they have one, the default one otherwise.
+//
+func loadPalette() [][]rune {
+	path, err := palettePath()
+	if err != nil {
+		return defaultPalette
+	}
+	fin, err := os.Open(path)
+	if err != nil {
+		return defaultPalette
+	}
+	defer fin.Close()
+
+	palette, err := readPalette(fin)
+	if err != nil || len(palette) == 0 {
+		return defaultPalette
+	}
+	return palette
+}
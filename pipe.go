@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// The filename that stands for stdin when loading and stdout when saving.
+//
+const stdioName = "-"
+
+//
+// A save to stdout, which has to wait until the program exits: until then
+// stdout may well be the terminal being drawn on.
+//
+type stdoutSavedMsg struct {
+	data []byte
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode() & os.ModeCharDevice != 0
+}
+
+//
+// Read a canvas piped to stdin, as if from a file given on the command
+// line, or a blank canvas if stdin is the terminal rather than a pipe.
+//
+func readStdin(tabWidth int) (width, height int, layers []layer, ok bool, err error) {
+	if isTerminal(os.Stdin) {
+		return 0, 0, nil, false, nil
+	}
+	h, layers, _, err := readArt(os.Stdin, tabWidth)
+	if err == errEmpty {
+		return 0, 0, nil, false, nil
+	}
+	return h.Width, h.Height, layers, err == nil, err
+}
+
+func saveStdout(layers []layer, width, height int, marks map[string]point, trimSafe bool) tea.Msg {
+	var buffer bytes.Buffer
+	if err := canvas.Save(layers, width, height, marks, trimSafe, &buffer); err != nil {
+		return errMsg{err}
+	}
+	return stdoutSavedMsg{buffer.Bytes()}
+}
+
+//
+// When stdin or stdout is a pipe, the terminal has to be reached some other
+// way.
+//
+func terminalOptions() ([]tea.ProgramOption, error) {
+	var options []tea.ProgramOption
+	if !isTerminal(os.Stdin) {
+		//
+		// Bubbletea opens the terminal itself.
+		//
+		options = append(options, tea.WithInputTTY())
+	}
+	if !isTerminal(os.Stdout) {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, tea.WithOutput(tty))
+	}
+	return options, nil
+}
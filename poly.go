@@ -0,0 +1,61 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+type polyArmedMsg struct {
+	closed bool
+}
+
+func polyCommand(m model, arg string) tea.Msg {
+	if arg != "closed" {
+		return errMsg{errUsage}
+	}
+	return polyArmedMsg{true}
+}
+
+//
+// The first click of a polyline places its first vertex, and each one after
+// that draws a line on to it from the last.  Clicking the first vertex again
+// closes the shape, and clicking the last one again ends the path, as does
+// the paint key.  The whole polyline is a single undo step.
+//
+func (m *model) polyClick(x, y int, brush pixel) {
+	x, y = m.constrained(m.snapped(x, y))
+	if !m.anchorSet {
+		m.anchorX, m.anchorY, m.anchorSet = x, y, true
+		m.polyStartX, m.polyStartY = x, y
+		m.polyDrawn = false
+		return
+	}
+	if x == m.anchorX && y == m.anchorY {
+		m.endPoly(brush)
+		return
+	}
+	m.polySegment(x, y, brush)
+	if x == m.polyStartX && y == m.polyStartY {
+		m.tool = toolPaint
+		m.anchorSet = false
+	}
+}
+
+func (m *model) polySegment(x, y int, brush pixel) {
+	if !m.polyDrawn {
+		m.pushHistory()
+		m.polyDrawn = true
+	}
+	m.drawShape(m.canvas(), x, y, brush)
+	m.anchorX, m.anchorY = x, y
+}
+
+//
+// Finish the polyline where it is, going back to the first vertex if it was
+// armed with :poly closed.
+//
+func (m *model) endPoly(brush pixel) {
+	atStart := m.anchorX == m.polyStartX && m.anchorY == m.polyStartY
+	if m.anchorSet && m.polyClosed && m.polyDrawn && !atStart {
+		m.polySegment(m.polyStartX, m.polyStartY, brush)
+	}
+	m.tool = toolPaint
+	m.anchorSet = false
+}
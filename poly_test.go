@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+//
+// Each click draws on from the last vertex, clicking the first one again
+// closes the shape, and a single undo takes the whole polyline away.
+//
+func TestPoly(t *testing.T) {
+	m := run(t, testModel(5, 3), "poly")
+	for _, p := range []point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 2}, {X: 0, Y: 0}} {
+		m.polyClick(p.X, p.Y, m.brushPrimary)
+	}
+	want := []string{"#####", " ## #", "   ##"}
+	if got := regionRows(m.canvas().Cells()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if m.tool != toolPaint || m.anchorSet {
+		t.Errorf("still drawing after closing the polyline")
+	}
+	m.undo()
+	if got := regionRows(m.canvas().Cells()); !reflect.DeepEqual(got, []string{"     ", "     ", "     "}) {
+		t.Errorf("after undo got %q", got)
+	}
+}
+
+//
+// Ending an open path armed with :poly closed draws the closing side.
+//
+func TestPolyClosed(t *testing.T) {
+	m := run(t, testModel(5, 3), "poly closed")
+	for _, p := range []point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 2}} {
+		m.polyClick(p.X, p.Y, m.brushPrimary)
+	}
+	m.endPoly(m.brushPrimary)
+	want := []string{"#####", " ## #", "   ##"}
+	if got := regionRows(m.canvas().Cells()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, ok := interpretCmd(m, "poly open")().(errMsg); !ok {
+		t.Errorf(":poly open didn't fail")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+//
+// Where the old content goes within the new size: an anchor of 0 keeps it
+// at the top (or left), 1 centers it and 2 puts it at the bottom (or right).
+//
+type anchor struct {
+	h, v int
+}
+
+var anchors = map[string]anchor{
+	"topleft": {0, 0},
+	"top": {1, 0},
+	"topright": {2, 0},
+	"left": {0, 1},
+	"center": {1, 1},
+	"right": {2, 1},
+	"bottomleft": {0, 2},
+	"bottom": {1, 2},
+	"bottomright": {2, 2},
+}
+
+//
+// How far to move content when going from oldW x oldH to newW x newH.
+// When centering leaves an odd cell over, it goes on the right (or bottom)
+// when growing, and is taken from there when shrinking.
+//
+func anchorOffset(oldW, oldH, newW, newH int, a anchor) (dx, dy int) {
+	return (newW - oldW) * a.h / 2, (newH - oldH) * a.v / 2
+}
+
+//
+// Whether moving the content by (dx, dy) and cropping to newW x newH would
+// lose anything other than blank cells.
+//
+func cropsContent(canvas Canvas, newW, newH, dx, dy int) bool {
+	for y, row := range canvas.Cells() {
+		for x, p := range row {
+			nx, ny := x + dx, y + dy
+			if (nx < 0 || ny < 0 || nx >= newW || ny >= newH) && !p.IsBlank() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//
+// Resize every layer, keeping the content at the top-left.  Returns true
+// if any content got cropped.
+//
+func (m *model) resize(width, height int) (cropped bool) {
+	return m.resizeAnchored(width, height, anchors["topleft"])
+}
+
+//
+// Resize every layer, positioning the content according to a.  The bottom
+// layer is padded with spaces and the ones above it with transparency, so
+// that the new area looks blank.
+//
+func (m *model) resizeAnchored(width, height int, a anchor) (cropped bool) {
+	m.pushHistory()
+	normalizeLayers(m.layers, m.width, m.height)
+	dx, dy := anchorOffset(m.width, m.height, width, height, a)
+	for i := range m.layers {
+		fill := transparent
+		if i == 0 {
+			fill = pixel{R: ' '}
+		}
+		if cropsContent(m.layers[i].Grid, width, height, dx, dy) {
+			cropped = true
+		}
+		m.layers[i].Grid = m.layers[i].Grid.Resize(width, height, dx, dy, fill)
+	}
+	m.width, m.height = width, height
+	return cropped
+}
+
+//
+// The smallest rectangle holding everything in canvas other than blank
+// cells, corners inclusive, or empty if there's nothing but blanks.  Wide
+// glyphs are held whole, padding and all.
+//
+func contentBounds(canvas Canvas) (x0, y0, x1, y1 int, empty bool) {
+	x0, y0, x1, y1 = canvas.Width(), canvas.Height(), -1, -1
+	for y, row := range canvas.Cells() {
+		for x, p := range row {
+			if p.IsBlank() {
+				continue
+			}
+			right := x
+			if x + 1 < len(row) && row[x + 1] == padding {
+				right = x + 1
+			}
+			x0, y0 = min(x0, x), min(y0, y)
+			x1, y1 = max(x1, right), max(y1, y)
+		}
+	}
+	if x1 < 0 {
+		return 0, 0, 0, 0, true
+	}
+	return x0, y0, x1, y1, false
+}
+
+//
+// Crop every layer to the content of all of them, hidden ones included.
+// Returns false, leaving the canvas alone, if there's no content at all.
+//
+func (m *model) trim() bool {
+	empty := true
+	var bounds selection
+	for _, l := range m.layers {
+		x0, y0, x1, y1, blank := contentBounds(l.Grid)
+		if blank {
+			continue
+		}
+		if empty {
+			bounds, empty = selection{x0, y0, x1, y1}, false
+			continue
+		}
+		bounds = selection{min(bounds.x0, x0), min(bounds.y0, y0), max(bounds.x1, x1), max(bounds.y1, y1)}
+	}
+	if empty {
+		return false
+	}
+	m.pushHistory()
+	width, height := bounds.x1 - bounds.x0 + 1, bounds.y1 - bounds.y0 + 1
+	for i := range m.layers {
+		m.layers[i].Grid = m.layers[i].Grid.Resize(width, height, -bounds.x0, -bounds.y0, transparent)
+	}
+	m.width, m.height = width, height
+	return true
+}
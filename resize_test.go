@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func TestContentBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		c Canvas
+		x0, y0, x1, y1 int
+		empty bool
+	}{
+		{"blank", canvasOf("   ", "   "), 0, 0, 0, 0, true},
+		{"transparent", canvasOf("...", "..."), 0, 0, 0, 0, true},
+		{"one cell", canvasOf("   ", " x "), 1, 1, 1, 1, false},
+		{"spread out", canvasOf("a  .", "   .", "  b."), 0, 0, 2, 2, false},
+		{"wide", canvasOf("  世"), 2, 0, 3, 0, false},
+		{"background color", coloredBlank(canvasOf("   ", "   "), 1, 1), 1, 1, 1, 1, false},
+	}
+	for _, test := range tests {
+		x0, y0, x1, y1, empty := contentBounds(test.c)
+		if x0 != test.x0 || y0 != test.y0 || x1 != test.x1 || y1 != test.y1 || empty != test.empty {
+			t.Errorf("%s: got %d,%d %d,%d %v, want %d,%d %d,%d %v", test.name, x0, y0, x1, y1, empty, test.x0, test.y0, test.x1, test.y1, test.empty)
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	m := testModel(5, 4)
+	if m.trim() {
+		t.Errorf("trimmed a blank canvas")
+	}
+	m.layers[0].Grid = canvasOf("     ", " a   ", "     ", "     ")
+	m.layers = append(m.layers, canvas.NewLayer(5, 4))
+	m.layers[1].Grid.Set(3, 2, pixel{R: 'b'})
+	if !m.trim() {
+		t.Fatalf("didn't trim")
+	}
+	if m.width != 3 || m.height != 2 {
+		t.Errorf("got %dx%d, want 3x2", m.width, m.height)
+	}
+	if got, want := regionRows(m.layers[0].Grid.Cells()), []string{"a  ", "   "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bottom layer: got %q, want %q", got, want)
+	}
+	if got, want := regionRows(m.layers[1].Grid.Cells()), []string{"...", "..b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top layer: got %q, want %q", got, want)
+	}
+
+	m = testModel(4, 2)
+	m.layers[0].Grid = canvasOf("    ", " 世 ")
+	if !m.trim() || m.width != 2 || m.height != 1 {
+		t.Fatalf("got %dx%d trimming a wide glyph, want 2x1", m.width, m.height)
+	}
+	if got, want := regionRows(m.canvas().Cells()), []string{"世"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wide glyph: got %q, want %q", got, want)
+	}
+}
+
+//
+// c with a blank of a background color at (x, y), which is a solid block
+// rather than nothing.
+//
+func coloredBlank(c Canvas, x, y int) Canvas {
+	c.Set(x, y, pixel{R: ' ', BG: "4"})
+	return c
+}
+
+func TestCropsContent(t *testing.T) {
+	tests := []struct {
+		name string
+		c Canvas
+		width, height, dx, dy int
+		want bool
+	}{
+		{"blanks", canvasOf("  .", "  ."), 1, 1, 0, 0, false},
+		{"kept", canvasOf("a  ", "   "), 1, 1, 0, 0, false},
+		{"cropped", canvasOf("  a", "   "), 2, 2, 0, 0, true},
+		{"grown", canvasOf("ab", "cd"), 3, 3, 0, 0, false},
+		{"moved off", canvasOf("a  ", "   "), 3, 2, -1, 0, true},
+		{"background color", coloredBlank(canvasOf("   ", "   "), 2, 1), 2, 2, 0, 0, true},
+	}
+	for _, test := range tests {
+		if got := cropsContent(test.c, test.width, test.height, test.dx, test.dy); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAnchorOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		oldW, oldH, newW, newH int
+		a string
+		dx, dy int
+	}{
+		{"top left", 4, 4, 10, 8, "topleft", 0, 0},
+		{"bottom right", 4, 4, 10, 8, "bottomright", 6, 4},
+		{"center, even", 4, 4, 10, 8, "center", 3, 2},
+		{"center, odd growing", 4, 4, 9, 7, "center", 2, 1},
+		{"center, odd shrinking", 9, 7, 4, 4, "center", -2, -1},
+		{"center, even shrinking", 10, 8, 4, 4, "center", -3, -2},
+		{"top", 4, 4, 7, 6, "top", 1, 0},
+		{"right", 4, 4, 7, 6, "right", 3, 1},
+		{"same size", 4, 4, 4, 4, "bottomright", 0, 0},
+	}
+	for _, test := range tests {
+		dx, dy := anchorOffset(test.oldW, test.oldH, test.newW, test.newH, anchors[test.a])
+		if dx != test.dx || dy != test.dy {
+			t.Errorf("%s: got %d, %d, want %d, %d", test.name, dx, dy, test.dx, test.dy)
+		}
+	}
+}
+
+func TestResizeAnchored(t *testing.T) {
+	m := testModel(2, 2)
+	m.layers = []layer{{Grid: canvasOf("ab", "cd"), Visible: true}, {Grid: canvasOf("..", ".x"), Visible: true}}
+	if m.resizeAnchored(5, 3, anchors["center"]) {
+		t.Errorf("cropped growing")
+	}
+	if got, want := regionRows(m.layers[0].Grid.Cells()), []string{" ab  ", " cd  ", "     "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bottom layer: got %q, want %q", got, want)
+	}
+	if got, want := regionRows(m.layers[1].Grid.Cells()), []string{".....", "..x..", "....."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top layer: got %q, want %q", got, want)
+	}
+	if !m.resizeAnchored(2, 2, anchors["bottomright"]) || m.width != 2 || m.height != 2 {
+		t.Errorf("got %dx%d, without cropping, shrinking from the bottom right", m.width, m.height)
+	}
+	if _, err := m.apply(interpretCmd(m, "canvas 5000 3")()); err == nil || m.width != 2 {
+		t.Errorf("got %v resizing past the limit", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// The rendered text of each row of the last frame, so that View only has to
+// format the rows that changed since.  The model holds it by pointer, so
+// that it survives the copying of the model between updates.
+//
+type rowCache struct {
+	rows []cachedRow
+}
+
+type cachedRow struct {
+	cells []pixel
+	text string
+}
+
+//
+// The row, formatted by canvas.FormatRow, from the cache if it's the same as last
+// time.  A nil cache renders every time.
+//
+func (c *rowCache) render(y int, row []pixel) string {
+	if c != nil && y < len(c.rows) && slices.Equal(c.rows[y].cells, row) {
+		return c.rows[y].text
+	}
+	text := canvas.FormatRow(row)
+	if c != nil {
+		for len(c.rows) <= y {
+			c.rows = append(c.rows, cachedRow{})
+		}
+		c.rows[y] = cachedRow{slices.Clone(row), text}
+	}
+	return text
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//
+// The commands in a script, one line per command (or several separated by
+// |, like on the command line).  Blank lines and lines starting with # are
+// skipped, but still counted, so that errors point at the right line.
+//
+func readScript(filename string) ([]commandLine, error) {
+	fin, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	var lines []commandLine
+	scanner := bufio.NewScanner(fin)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		for _, command := range splitCommands(text) {
+			lines = append(lines, commandLine{n, command})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return lines, nil
+}
+
+//
+// Seen by apply when a command quits gopnik.
+//
+var errQuit = errors.New("quit")
+
+//
+// Feed msg to Update, then everything that the commands it returns result
+// in, until there's nothing left to do, the way the program would.  This
+// only works headless, where nothing waits on a timer.  Anything shown as
+// an error is returned as one.
+//
+func (m model) apply(msg tea.Msg) (model, error) {
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		msg, queue = queue[0], queue[1:]
+		switch msg := msg.(type) {
+		case nil:
+			continue
+		case tea.QuitMsg:
+			return m, errQuit
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				if cmd != nil {
+					queue = append(queue, cmd())
+				}
+			}
+			continue
+		}
+
+		id := m.statusID
+		next, cmd := m.Update(msg)
+		m = next.(model)
+		if m.statusID != id && m.statusErr {
+			return m, errors.New(m.status)
+		}
+		if cmd != nil {
+			queue = append(queue, cmd())
+		}
+	}
+	return m, nil
+}
+
+//
+// Run without the editor, e.g. to generate or convert art in a build: load
+// the file given on the command line, if any, run script, if any, and write
+// the result to output, if any.  The output's extension picks the format,
+// like with :export, and anything else is saved as a gopnik file.
+//
+func runHeadless(m model, script, output string) (model, error) {
+	m.headless = true
+	m.autosaveInterval = 0
+
+	var lines []commandLine
+	var err error
+	if script != "" {
+		if lines, err = readScript(script); err != nil {
+			return m, err
+		}
+	}
+	if m.startupFile != "" {
+		msg := loadFile(m.startupFile)
+		if m.startupSniff {
+			msg = openFile(m.startupFile, m.tabWidth)
+		}
+		if m, err = m.apply(msg); err != nil {
+			return m, err
+		}
+	}
+	for _, line := range lines {
+		m, err = m.apply(runCommand(m, line.text))
+		if err == errQuit {
+			break
+		} else if err != nil {
+			return m, fmt.Errorf("%s:%d: %w", script, line.n, err)
+		}
+	}
+
+	if output == "" {
+		return m, nil
+	}
+	verb := "save"
+	if _, ok := exporters[strings.ToLower(filepath.Ext(output))]; ok {
+		verb = "export"
+	}
+	if m, err = m.apply(runCommand(m, verb + " " + output)); err != nil {
+		return m, fmt.Errorf("%s: %w", output, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//
+// A script runs a line at a time, and a failure says which line it was,
+// counting the comments and blank lines skipped on the way.
+//
+func TestRunHeadless(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(script, []byte("# a comment\n\nbrush x | resize 6 3\nresize 0 0\nbrush z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := runHeadless(testModel(4, 2), script, "")
+	if want := script + ":4: bad resize command \"0 0\""; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+	if m.brushPrimary.R != 'x' || m.width != 6 {
+		t.Errorf("got brush %c and width %d, want x and 6", m.brushPrimary.R, m.width)
+	}
+}
+
+//
+// With no script, the file given on the command line is just converted to
+// whatever the output's extension says.
+//
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(input, []byte("3 2\nabc\nd f\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		output string
+		want []string
+	}{
+		{"out.txt", []string{"gopnik v", "\nabc\n", "\nd f\n"}},
+		{"out.html", []string{"<html", "abc"}},
+		{"out.svg", []string{"<svg", ">a</text>"}},
+	} {
+		m := testModel(1, 1)
+		m.startupFile = input
+		output := filepath.Join(dir, test.output)
+		if _, err := runHeadless(m, "", output); err != nil {
+			t.Errorf("%s: %v", test.output, err)
+			continue
+		}
+		b, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s: no %q in %q", test.output, want, b)
+			}
+		}
+	}
+}
+
+func TestHeadlessAutosave(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script")
+	if err := os.WriteFile(script, []byte("autosave 1\nbrush x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := runHeadless(testModel(2, 2), script, filepath.Join(dir, "out.txt"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting on an autosave tick")
+	}
+}
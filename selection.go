@@ -0,0 +1,56 @@
+package main
+
+//
+// A rectangular region of the canvas, corners inclusive.  The corners are
+// stored in the order they were dragged, so use normalized before iterating.
+//
+type selection struct {
+	x0, y0, x1, y1 int
+}
+
+func (s selection) normalized() selection {
+	if s.x0 > s.x1 {
+		s.x0, s.x1 = s.x1, s.x0
+	}
+	if s.y0 > s.y1 {
+		s.y0, s.y1 = s.y1, s.y0
+	}
+	return s
+}
+
+//
+// Returns a copy of the selected cells.  Parts of the selection that fall
+// outside the canvas are dropped.
+//
+func extractRegion(canvas Canvas, s selection) [][]pixel {
+	s = s.normalized()
+	var region [][]pixel
+	for y := s.y0; y <= s.y1; y++ {
+		if y < 0 || y >= canvas.Height() {
+			continue
+		}
+		var row []pixel
+		for x := s.x0; x <= s.x1; x++ {
+			if canvas.In(x, y) {
+				row = append(row, canvas.At(x, y))
+			}
+		}
+		region = append(region, row)
+	}
+	return region
+}
+
+//
+// Copy region onto the canvas with its top-left corner at (x, y), clipping
+// whatever doesn't fit.  Transparent cells leave the canvas untouched, and
+// so do spaces if blanks is set.
+//
+func pasteRegion(canvas Canvas, region [][]pixel, x, y int, blanks bool) {
+	for dy := range region {
+		for dx := range region[dy] {
+			if p := region[dy][dx]; p != transparent && !(blanks && p.R == ' ') {
+				canvas.Set(x + dx, y + dy, region[dy][dx])
+			}
+		}
+	}
+}
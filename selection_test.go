@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// A canvas with a row for each of lines, with dots for transparency.  Wide
+// glyphs get their padding, so a line is as long as it looks.
+//
+func canvasOf(lines ...string) Canvas {
+	c := canvas.Filled(runewidth.StringWidth(lines[0]), len(lines), transparent)
+	for y, line := range lines {
+		x := 0
+		for _, r := range line {
+			if r != '.' {
+				c.Set(x, y, pixel{R: r})
+			}
+			x += runewidth.RuneWidth(r)
+		}
+	}
+	return c
+}
+
+//
+// The glyphs of region a row at a time, the other way round from canvasOf.
+//
+func regionRows(region [][]pixel) []string {
+	var out []string
+	for _, row := range region {
+		var b strings.Builder
+		for _, p := range row {
+			switch p {
+			case transparent:
+				b.WriteRune('.')
+			case padding:
+			default:
+				b.WriteRune(p.R)
+			}
+		}
+		out = append(out, b.String())
+	}
+	return out
+}
+
+func TestExtractRegion(t *testing.T) {
+	c := canvasOf(
+		"abc",
+		"def",
+		"ghi",
+	)
+	tests := []struct {
+		name string
+		s selection
+		want []string
+	}{
+		{"cell", selection{1, 1, 1, 1}, []string{"e"}},
+		{"dragged backwards", selection{2, 1, 0, 0}, []string{"abc", "def"}},
+		{"whole", selection{0, 0, 2, 2}, []string{"abc", "def", "ghi"}},
+		{"clipped", selection{-1, 1, 1, 5}, []string{"de", "gh"}},
+		{"off the canvas", selection{4, 4, 6, 6}, nil},
+	}
+	for _, test := range tests {
+		if got := regionRows(extractRegion(c, test.s)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	region := extractRegion(c, selection{0, 0, 0, 0})
+	region[0][0] = pixel{R: 'x'}
+	if c.At(0, 0) != (pixel{R: 'a'}) {
+		t.Errorf("the region shares its cells with the canvas")
+	}
+}
+
+func TestFillSelection(t *testing.T) {
+	m := update(testModel(4, 3), fillSelectionMsg{})
+	if m.status != "nothing selected" || !m.statusErr {
+		t.Errorf("got %q with nothing selected", m.status)
+	}
+	m.selection, m.hasSelection = selection{2, 2, 1, 0}, true
+	m = update(m, fillSelectionMsg{})
+	want := []string{" ## ", " ## ", " ## "}
+	if got := regionRows(m.canvas().Cells()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	m = update(m, undoMsg{})
+	if got := regionRows(m.canvas().Cells()); !reflect.DeepEqual(got, []string{"    ", "    ", "    "}) {
+		t.Errorf("got %q after undoing", got)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Everything needed to pick up where :mksession left off: every tab, with
+// its canvas, brushes and cursor, and the editor settings that aren't per
+// tab.  Undo history and the clipboard aren't kept.
+//
+type session struct {
+	Tabs []sessionTab `json:"tabs"`
+	ActiveTab int `json:"active_tab"`
+	BrushSize int `json:"brush_size"`
+	BrushShape string `json:"brush_shape"`
+	GridSize int `json:"grid_size,omitempty"`
+	GridRune string `json:"grid_char,omitempty"`
+	Palette []string `json:"palette"`
+}
+
+//
+// The canvas is kept in gopnik's own file format, layers, marks and all,
+// so that it reads as well as a saved file does.  A tab with unsaved
+// changes is still dirty once restored.
+//
+type sessionTab struct {
+	Filename string `json:"filename,omitempty"`
+	Dirty bool `json:"dirty,omitempty"`
+	Canvas string `json:"canvas"`
+	ActiveLayer int `json:"active_layer"`
+	Primary sessionBrush `json:"primary"`
+	Secondary sessionBrush `json:"secondary"`
+	CursorX int `json:"cursor_x"`
+	CursorY int `json:"cursor_y"`
+	ViewX int `json:"view_x"`
+	ViewY int `json:"view_y"`
+}
+
+//
+// A transparent brush has an empty glyph.
+//
+type sessionBrush struct {
+	Glyph string `json:"glyph"`
+	FG color `json:"fg,omitempty"`
+	BG color `json:"bg,omitempty"`
+	Attrs attrs `json:"attrs,omitempty"`
+}
+
+func newSessionBrush(p pixel) sessionBrush {
+	b := sessionBrush{FG: p.FG, BG: p.BG, Attrs: p.Attrs}
+	if p != transparent {
+		b.Glyph = string(p.R)
+	}
+	return b
+}
+
+func (b sessionBrush) pixel() (pixel, error) {
+	p := pixel{FG: b.FG, BG: b.BG, Attrs: b.Attrs}
+	if b.Glyph != "" {
+		r, size := utf8.DecodeRuneInString(b.Glyph)
+		if size != len(b.Glyph) || r == utf8.RuneError {
+			return p, fmt.Errorf("bad glyph %q", b.Glyph)
+		}
+		p.R = r
+	}
+	for _, c := range []color{b.FG, b.BG} {
+		if _, err := canvas.ParseColor(string(c)); c != noColor && err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+func newSession(m model) (session, error) {
+	s := session{
+		ActiveTab: m.activeTab,
+		BrushSize: m.brushSize,
+		BrushShape: m.brushShape.String(),
+		GridSize: m.gridSize,
+	}
+	if m.gridRune != 0 {
+		s.GridRune = string(m.gridRune)
+	}
+	for _, group := range m.palette {
+		s.Palette = append(s.Palette, string(group))
+	}
+	tabs := m.tabs
+	if len(tabs) == 0 {
+		tabs = []document{m.document}
+	}
+	for i, doc := range tabs {
+		if i == m.activeTab {
+			doc = m.document
+		}
+		var buffer bytes.Buffer
+		if err := canvas.Save(doc.layers, doc.width, doc.height, doc.marks, false, &buffer); err != nil {
+			return s, err
+		}
+		s.Tabs = append(s.Tabs, sessionTab{
+			Filename: doc.filename,
+			Dirty: doc.dirty,
+			Canvas: buffer.String(),
+			ActiveLayer: doc.activeLayer,
+			Primary: newSessionBrush(doc.brushPrimary),
+			Secondary: newSessionBrush(doc.brushSecondary),
+			CursorX: doc.cursorX,
+			CursorY: doc.cursorY,
+			ViewX: doc.viewX,
+			ViewY: doc.viewY,
+		})
+	}
+	return s, nil
+}
+
+func saveSession(m model, filename string) tea.Msg {
+	s, err := newSession(m)
+	if err != nil {
+		return errMsg{err}
+	}
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return errMsg{err}
+	}
+	if err := os.WriteFile(filename, append(data, '\n'), 0o644); err != nil {
+		return errMsg{err}
+	}
+	return statusMsg{fmt.Sprintf("session saved to %s", filename)}
+}
+
+func (t sessionTab) document() (document, error) {
+	var doc document
+	h, layers, err := canvas.Load(strings.NewReader(t.Canvas))
+	if err != nil {
+		return doc, err
+	}
+	if t.ActiveLayer < 0 || t.ActiveLayer >= len(layers) {
+		return doc, fmt.Errorf("bad active layer %d: expected 0-%d", t.ActiveLayer, len(layers) - 1)
+	}
+	doc = document{
+		width: h.Width,
+		height: h.Height,
+		layers: layers,
+		activeLayer: t.ActiveLayer,
+		filename: t.Filename,
+		dirty: t.Dirty,
+		backupStale: t.Dirty,
+		marks: h.Marks,
+		cursorX: clamp(t.CursorX, 0, h.Width - 1),
+		cursorY: clamp(t.CursorY, 0, h.Height - 1),
+		viewX: max(t.ViewX, 0),
+		viewY: max(t.ViewY, 0),
+	}
+	if len(doc.marks) == 0 {
+		doc.marks = nil
+	}
+	if doc.brushPrimary, err = t.Primary.pixel(); err != nil {
+		return doc, fmt.Errorf("primary brush: %w", err)
+	}
+	if doc.brushSecondary, err = t.Secondary.pixel(); err != nil {
+		return doc, fmt.Errorf("secondary brush: %w", err)
+	}
+	return doc, nil
+}
+
+//
+// Replace the tabs and settings of m with those saved in filename by
+// :mksession.  Nothing changes unless the whole session can be read.
+//
+func restoreSession(m model, filename string) (model, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return m, err
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return m, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(s.Tabs) == 0 {
+		return m, fmt.Errorf("%s: no tabs", filename)
+	} else if s.ActiveTab < 0 || s.ActiveTab >= len(s.Tabs) {
+		return m, fmt.Errorf("%s: bad active tab %d: expected 0-%d", filename, s.ActiveTab, len(s.Tabs) - 1)
+	} else if s.BrushSize < 1 || s.BrushSize > maxBrushSize {
+		return m, fmt.Errorf("%s: bad brush size %d: expected 1-%d", filename, s.BrushSize, maxBrushSize)
+	}
+	shape, err := parseBrushShape(s.BrushShape)
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", filename, err)
+	}
+	gridRune := defaultGridRune
+	if s.GridRune != "" {
+		gridRune, _ = utf8.DecodeRuneInString(s.GridRune)
+	}
+	var tabs []document
+	for i, t := range s.Tabs {
+		doc, err := t.document()
+		if err != nil {
+			return m, fmt.Errorf("%s: tab %d: %w", filename, i + 1, err)
+		}
+		tabs = append(tabs, doc)
+	}
+
+	m.tabs, m.activeTab, m.document = tabs, s.ActiveTab, tabs[s.ActiveTab]
+	m.brushSize, m.brushShape = s.BrushSize, shape
+	m.gridSize, m.gridRune = s.GridSize, gridRune
+	m.palette = nil
+	for _, group := range s.Palette {
+		if g := []rune(group); len(g) > 0 {
+			m.palette = append(m.palette, g)
+		}
+	}
+	return m, nil
+}
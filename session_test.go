@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	m := testModel(6, 3)
+	m.palette = [][]rune{[]rune("─│┌"), []rune("░▒▓")}
+	m = run(t, m, "brush x", "color red", "line", "size 3", "shape circle", "grid 2 +", "layer new")
+	m.cursorX, m.cursorY = 2, 1
+	m = run(t, m, "mark a")
+	m.canvas().Set(1, 1, pixel{R: '漢', BG: "4", Attrs: attrBold})
+	m.cursorX, m.cursorY = 4, 2
+	m = run(t, m, "tabnew", "new 3 2", "brush 2 U+2588")
+	m.filename, m.dirty = "second.txt", false
+
+	filename := filepath.Join(t.TempDir(), "session.json")
+	if _, err := m.apply(saveSession(m, filename)); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := restoreSession(testModel(1, 1), filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := newSession(m)
+	got, _ := newSession(restored)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if len(restored.tabs) != 2 || restored.activeTab != 1 || restored.filename != "second.txt" {
+		t.Errorf("got %d tabs, on tab %d, %q", len(restored.tabs), restored.activeTab, restored.filename)
+	}
+	first := restored.tabs[0]
+	if first.cursorX != 4 || first.cursorY != 2 || len(first.layers) != 2 || first.marks["a"] != (point{X: 2, Y: 1}) || !first.dirty {
+		t.Errorf("first tab: cursor %d, %d, %d layers, marks %v, dirty %v", first.cursorX, first.cursorY, len(first.layers), first.marks, first.dirty)
+	}
+	if p := first.layers[1].Grid.At(1, 1); p != (pixel{R: '漢', BG: "4", Attrs: attrBold}) {
+		t.Errorf("first tab: got %+v", p)
+	}
+	if restored.brushSize != 3 || restored.brushShape != brushCircle || restored.gridSize != 2 || restored.gridRune != '+' {
+		t.Errorf("got brush %d %v and grid %d %c", restored.brushSize, restored.brushShape, restored.gridSize, restored.gridRune)
+	}
+}
+
+func TestRestoreSessionErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"not json": "tabs",
+		"no tabs": `{"tabs": [], "brush_size": 1, "brush_shape": "square"}`,
+		"bad active tab": `{"tabs": [{"canvas": "1 1\nx\n"}], "active_tab": 1, "brush_size": 1, "brush_shape": "square"}`,
+		"bad brush size": `{"tabs": [{"canvas": "1 1\nx\n"}], "brush_size": 0, "brush_shape": "square"}`,
+		"bad canvas": `{"tabs": [{"canvas": "2 1\nx\n"}], "brush_size": 1, "brush_shape": "square"}`,
+		"bad glyph": `{"tabs": [{"canvas": "1 1\nx\n", "primary": {"glyph": "xy"}}], "brush_size": 1, "brush_shape": "square"}`,
+	} {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		m := testModel(2, 2)
+		restored, err := restoreSession(m, filename)
+		if err == nil {
+			t.Errorf("%s: got no error", name)
+		} else if !reflect.DeepEqual(restored, m) {
+			t.Errorf("%s: half restored", name)
+		}
+	}
+}
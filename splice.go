@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+//
+// Insert a blank row or column, or delete one, moving everything after it
+// along.
+//
+type spliceMsg struct {
+	column bool
+	at int
+	insert bool
+}
+
+//
+// Apply msg to every layer.  Rows and columns are numbered from 0, like
+// the cursor position in the status bar.
+//
+func (m *model) splice(msg spliceMsg) error {
+	size, limit, name := m.height, maxCanvasHeight, "row"
+	if msg.column {
+		size, limit, name = m.width, maxCanvasWidth, "column"
+	}
+	switch {
+	case msg.insert && size >= limit:
+		return fmt.Errorf("the canvas can't be more than %d %ss", limit, name)
+	case msg.insert && (msg.at < 0 || msg.at > size):
+		return fmt.Errorf("bad %s %d: expected 0-%d", name, msg.at, size)
+	case !msg.insert && (msg.at < 0 || msg.at >= size):
+		return fmt.Errorf("bad %s %d: expected 0-%d", name, msg.at, size - 1)
+	case !msg.insert && size == 1:
+		return fmt.Errorf("can't delete the only %s", name)
+	}
+
+	m.pushHistory()
+	for i := range m.layers {
+		fill := transparent
+		if i == 0 {
+			fill = pixel{R: ' '}
+		}
+		grid := m.layers[i].Grid
+		switch {
+		case msg.column && msg.insert:
+			grid = grid.InsertColumn(msg.at, fill)
+		case msg.column:
+			grid = grid.DeleteColumn(msg.at)
+		case msg.insert:
+			grid = grid.InsertRow(msg.at, fill)
+		default:
+			grid = grid.DeleteRow(msg.at)
+		}
+		m.layers[i].Grid = grid
+	}
+	m.width, m.height = m.layers[0].Grid.Bounds()
+	m.cursorX, m.cursorY = clamp(m.cursorX, 0, m.width - 1), clamp(m.cursorY, 0, m.height - 1)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplice(t *testing.T) {
+	m := testModel(3, 2)
+	m.layers[0].Grid = canvasOf("abc", "def")
+	m = run(t, m, "layer new", "insrow 2", "inscol 0", "delrow 0", "delcol 3")
+	if m.width != 3 || m.height != 2 {
+		t.Errorf("got %dx%d, want 3x2", m.width, m.height)
+	}
+	if got, want := regionRows(m.layers[0].Grid.Cells()), []string{" de", "   "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bottom layer: got %q, want %q", got, want)
+	}
+	if got, want := regionRows(m.layers[1].Grid.Cells()), []string{"...", "..."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top layer: got %q, want %q", got, want)
+	}
+
+	for _, test := range []struct {
+		line, want string
+	}{
+		{"insrow 3", "bad row 3: expected 0-2"},
+		{"delrow 2", "bad row 2: expected 0-1"},
+		{"inscol -1", "bad column -1: expected 0-3"},
+		{"delcol 3", "bad column 3: expected 0-2"},
+	} {
+		if _, err := m.apply(interpretCmd(m, test.line)()); err == nil || err.Error() != test.want {
+			t.Errorf(":%s: got %v, want %s", test.line, err, test.want)
+		}
+	}
+	m = testModel(1, 1)
+	if _, err := m.apply(interpretCmd(m, "delrow 0")()); err == nil || err.Error() != "can't delete the only row" {
+		t.Errorf(":delrow 0 of 1: got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//
+// How often the spray can adds paint while the button is held still.
+//
+const sprayInterval = 50 * time.Millisecond
+
+const (
+	maxSprayRadius = 32
+	maxSprayDensity = 100
+)
+
+type sprayArmedMsg struct {
+	radius, density int
+}
+
+type sprayTickMsg struct {
+	id int
+}
+
+//
+// Where one burst of spray lands: density points scattered evenly over the
+// disc of the given radius around (x, y).  The same seed always gives the
+// same points.
+//
+func sprayPoints(seed int64, x, y, radius, density int) [][2]int {
+	rng := rand.New(rand.NewSource(seed))
+	points := make([][2]int, 0, density)
+	for len(points) < density {
+		dx, dy := rng.Intn(2 * radius + 1) - radius, rng.Intn(2 * radius + 1) - radius
+		if dx * dx + dy * dy <= radius * radius {
+			points = append(points, [2]int{x + dx, y + dy})
+		}
+	}
+	return points
+}
+
+//
+// Spray a burst around the mouse.  Each burst gets the next seed, so that
+// bursts differ but a session can be replayed exactly.
+//
+func (m *model) sprayBurst() {
+	for _, p := range sprayPoints(m.spraySeed, m.mouseX, m.mouseY, m.sprayRadius, m.sprayDensity) {
+		m.canvas().Set(p[0], p[1], m.sprayBrush)
+	}
+	m.spraySeed++
+}
+
+//
+// Start spraying with brush, until the button is released.  Like status
+// messages, ticks from earlier presses are told apart by their id.
+//
+func (m *model) startSpray(brush pixel) tea.Cmd {
+	m.pushHistory()
+	m.sprayBrush, m.spraying = brush, true
+	m.sprayID++
+	m.sprayBurst()
+	return sprayTick(m.sprayID)
+}
+
+func sprayTick(id int) tea.Cmd {
+	return tea.Tick(sprayInterval, func(time.Time) tea.Msg {
+		return sprayTickMsg{id}
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSprayPoints(t *testing.T) {
+	tests := []struct {
+		x, y, radius, density int
+	}{
+		{10, 10, 3, 20},
+		{0, 0, 1, 50},
+		{5, 5, 0, 4},
+		{7, 2, 8, 0},
+	}
+	for _, test := range tests {
+		points := sprayPoints(1, test.x, test.y, test.radius, test.density)
+		if len(points) != test.density {
+			t.Errorf("%+v: got %d points", test, len(points))
+		}
+		for _, p := range points {
+			dx, dy := p[0] - test.x, p[1] - test.y
+			if dx * dx + dy * dy > test.radius * test.radius {
+				t.Errorf("%+v: %v is outside the disc", test, p)
+			}
+		}
+		if again := sprayPoints(1, test.x, test.y, test.radius, test.density); !reflect.DeepEqual(again, points) {
+			t.Errorf("%+v: the same seed gave %v, then %v", test, points, again)
+		}
+	}
+	if reflect.DeepEqual(sprayPoints(1, 10, 10, 5, 10), sprayPoints(2, 10, 10, 5, 10)) {
+		t.Errorf("different seeds gave the same points")
+	}
+}
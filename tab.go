@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Everything that belongs to one drawing rather than to the editor, so
+// that several can be open at once, one per tab.
+//
+type document struct {
+	width int
+	height int
+	layers []layer
+	activeLayer int
+	brushPrimary pixel
+	brushSecondary pixel
+
+	//
+	// Set when the canvas changes, cleared when it's saved or loaded.
+	// backupStale is the same, but cleared by autosave too.
+	//
+	dirty bool
+	backupStale bool
+
+	//
+	// Where the canvas was last saved to or loaded from.
+	//
+	filename string
+
+	selection selection
+	hasSelection bool
+
+	//
+	// The keyboard cursor, for painting without a mouse.  It's only shown
+	// once it's been used.
+	//
+	cursorX int
+	cursorY int
+	cursorVisible bool
+
+	//
+	// Positions saved with :mark, which are saved with the canvas too.
+	//
+	marks map[string]point
+
+	//
+	// The canvas position at the top-left of the terminal, when the canvas
+	// is bigger than the terminal.
+	//
+	viewX, viewY int
+
+	//
+	// Snapshots of the layers for undo/redo.  Entries before historyIndex
+	// are undoable, the rest (if any) are redoable.
+	//
+	history [][]layer
+	historyIndex int
+}
+
+func newDocument(width, height int, primary, secondary pixel) document {
+	return document{
+		width: width,
+		height: height,
+		layers: blankLayers(width, height),
+		brushPrimary: primary,
+		brushSecondary: secondary,
+	}
+}
+
+//
+// A blank drawing: a single layer of spaces.  It mustn't be transparent,
+// since there's nothing under the bottom layer to show through, and a
+// transparent cell there would be saved as a NUL.
+//
+func blankLayers(width, height int) []layer {
+	return []layer{{Grid: canvas.New(width, height), Visible: true}}
+}
+
+type tabNewMsg struct {
+	filename string
+}
+
+type tabSwitchMsg struct {
+	delta int
+}
+
+type tabCloseMsg struct {
+	force bool
+}
+
+//
+// Write the active document back to its tab, so that tabs is complete.
+//
+func (m *model) syncTab() {
+	if len(m.tabs) == 0 {
+		m.tabs = []document{m.document}
+		m.activeTab = 0
+	} else {
+		m.tabs[m.activeTab] = m.document
+	}
+}
+
+func (m *model) switchTab(index int) {
+	m.syncTab()
+	m.activeTab = index
+	m.document = m.tabs[index]
+}
+
+//
+// Open a blank tab the size of the current canvas, with the same brushes,
+// after the current one.
+//
+func (m *model) newTab() {
+	m.syncTab()
+	doc := newDocument(m.width, m.height, m.brushPrimary, m.brushSecondary)
+	m.tabs = append(m.tabs[:m.activeTab+1], append([]document{doc}, m.tabs[m.activeTab+1:]...)...)
+	m.switchTab(m.activeTab + 1)
+}
+
+//
+// Move delta tabs along, wrapping around at either end.
+//
+func (m *model) cycleTab(delta int) {
+	m.syncTab()
+	n := len(m.tabs)
+	m.switchTab(((m.activeTab + delta) % n + n) % n)
+}
+
+func (m *model) closeTab(force bool) error {
+	m.syncTab()
+	if len(m.tabs) == 1 {
+		return fmt.Errorf("can't close the last tab, use :q to quit")
+	}
+	if m.dirty && !force {
+		return fmt.Errorf("unsaved changes in this tab, use :tabclose! to close it anyway")
+	}
+	m.tabs = append(m.tabs[:m.activeTab], m.tabs[m.activeTab+1:]...)
+	m.activeTab = min(m.activeTab, len(m.tabs) - 1)
+	m.document = m.tabs[m.activeTab]
+	return nil
+}
+
+//
+// The first tab with unsaved changes, if any.
+//
+func (m *model) dirtyTab() (int, bool) {
+	m.syncTab()
+	for i, doc := range m.tabs {
+		if doc.dirty {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (d document) title() string {
+	title := d.filename
+	if title == "" {
+		title = "untitled"
+	}
+	if d.dirty {
+		title += " [+]"
+	}
+	return title
+}
+
+//
+// The open tabs, e.g. "1 cat.txt  [2 dog.txt [+]]  3 untitled", with the
+// active one bracketed.  Empty while there's only one.
+//
+func (m model) tabBar() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	titles := make([]string, len(m.tabs))
+	for i, doc := range m.tabs {
+		if i == m.activeTab {
+			doc = m.document
+		}
+		titles[i] = fmt.Sprintf("%d %s", i + 1, doc.title())
+		if i == m.activeTab {
+			titles[i] = "[" + titles[i] + "]"
+		}
+	}
+	return strings.Join(titles, "  ")
+}
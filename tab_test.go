@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+func TestNewDocumentSaves(t *testing.T) {
+	doc := newDocument(4, 2, pixel{R: '#'}, pixel{R: ' '})
+	var buffer bytes.Buffer
+	if err := canvas.Save(doc.layers, doc.width, doc.height, doc.marks, false, &buffer); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.IndexByte(buffer.Bytes(), 0) >= 0 {
+		t.Errorf("a blank document saves with NULs: %q", buffer.Bytes())
+	}
+}
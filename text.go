@@ -0,0 +1,64 @@
+package main
+
+import (
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Handle a key while the text tool has an insertion point.  Characters are
+// written at the insertion point, which advances to the right and stops at
+// the edge of the canvas.  Enter starts a new line below, at the column
+// where the text started.
+//
+func (m *model) typeText(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		m.textActive = false
+		m.tool = toolPaint
+		return
+	case "enter":
+		m.textX = m.textColumn
+		if m.textY + 1 < m.height {
+			m.textY++
+		}
+		return
+	case "backspace":
+		if m.textX > m.textColumn {
+			m.textX--
+			if m.textX > m.textColumn && m.canvas().At(m.textX, m.textY) == padding {
+				m.textX--
+			}
+			m.canvas().Set(m.textX, m.textY, m.brushPrimary.WithRune(' '))
+		}
+		return
+	}
+
+	var runes []rune
+	switch msg.Type {
+	case tea.KeyRunes:
+		runes = msg.Runes
+	case tea.KeySpace:
+		runes = []rune{' '}
+	}
+	for _, r := range runes {
+		//
+		// Pasted text can hold tabs and newlines, which become spaces,
+		// as they do when importing text.
+		//
+		if !unicode.IsGraphic(r) {
+			r = ' '
+		}
+		width := 1
+		if canvas.IsWide(r) {
+			width = 2
+		}
+		if m.textX + width > m.width {
+			return
+		}
+		m.canvas().Set(m.textX, m.textY, m.brushPrimary.WithRune(r))
+		m.textX += width
+	}
+}
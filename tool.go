@@ -0,0 +1,257 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// What a mouse click does.  Tools other than toolPaint are armed by a
+// command and apply to the next click (or pair of clicks) only.
+//
+type tool int
+
+const (
+	toolPaint tool = iota
+	toolFill
+	toolLine
+	toolRect
+	toolRectFill
+	toolEllipse
+	toolEllipseFill
+	toolSelect
+	toolPaste
+	toolPick
+	toolText
+	toolStamp
+	toolGradient
+	toolSpray
+	toolPoly
+)
+
+//
+// Tools that take two clicks: the first sets the anchor, the second draws.
+// For ellipses, the anchor is the center.  Polylines carry on from there,
+// with each click drawing on from the one before.
+//
+func (t tool) twoClick() bool {
+	switch t {
+	case toolLine, toolRect, toolRectFill, toolEllipse, toolEllipseFill, toolPoly:
+		return true
+	}
+	return false
+}
+
+func (m model) drawShape(canvas Canvas, x, y int, brush pixel) {
+	switch m.tool {
+	case toolLine, toolPoly:
+		if drawBoxLine(canvas, m.anchorX, m.anchorY, x, y, brush) {
+			break
+		} else if m.smooth {
+			drawSmoothLine(canvas, m.anchorX, m.anchorY, x, y, brush)
+		} else {
+			canvas.Line(m.anchorX, m.anchorY, x, y, brush)
+		}
+	case toolRect:
+		canvas.Rect(m.anchorX, m.anchorY, x, y, brush, false)
+	case toolRectFill:
+		canvas.Rect(m.anchorX, m.anchorY, x, y, brush, true)
+	case toolEllipse:
+		canvas.Ellipse(m.anchorX, m.anchorY, x - m.anchorX, y - m.anchorY, brush)
+	case toolEllipseFill:
+		canvas.FillEllipse(m.anchorX, m.anchorY, x - m.anchorX, y - m.anchorY, brush)
+	}
+}
+
+func (m *model) click(x, y int, button tea.MouseButton) tea.Cmd {
+	brush, _ := m.mouseBrush(button)
+	switch m.tool {
+	case toolFill:
+		m.pushHistory()
+		x = canvas.CellOwner(m.canvas().Cells()[y], x)
+		m.canvas().FloodFill(x, y, m.canvas().At(x, y), brush, m.fillDiagonal)
+		m.tool = toolPaint
+
+	case toolLine, toolRect, toolRectFill, toolEllipse, toolEllipseFill:
+		x, y = m.constrained(m.snapped(x, y))
+		if !m.anchorSet {
+			m.anchorX, m.anchorY, m.anchorSet = x, y, true
+			return nil
+		}
+		m.pushHistory()
+		m.drawShape(m.canvas(), x, y, brush)
+		m.anchorSet = false
+		m.tool = toolPaint
+
+	case toolPoly:
+		m.polyClick(x, y, brush)
+
+	case toolSelect, toolGradient:
+		//
+		// The gradient fills the selection when the button is released.
+		//
+		m.anchorX, m.anchorY, m.anchorSet = x, y, true
+		m.selection = selection{x, y, x, y}
+		m.hasSelection = true
+
+	case toolPaste:
+		m.pushHistory()
+		pasteRegion(m.canvas(), m.clipboard, x, y, m.transparentBlanks)
+		m.tool = toolPaint
+
+	case toolStamp:
+		//
+		// Unlike a paste, the stamp stays armed until cancelled.
+		//
+		m.pushHistory()
+		pasteRegion(m.canvas(), m.stampBrush, x, y, m.transparentBlanks)
+
+	case toolSpray:
+		//
+		// Like a stroke, all the spraying until the release is one undo
+		// step.
+		//
+		return m.startSpray(brush)
+
+	case toolText:
+		//
+		// The whole of the typing that follows is a single undo step.
+		//
+		m.pushHistory()
+		m.textX, m.textY, m.textColumn, m.textActive = x, y, x, true
+
+	case toolPick:
+		//
+		// Pick what's visible, which isn't necessarily on the active layer.
+		//
+		m.tool = toolPaint
+		visible := canvas.Composite(m.layers, m.width, m.height)
+		picked, slot := visible.At(canvas.CellOwner(visible.Cells()[y], x), y), 1
+		if button == tea.MouseButtonRight {
+			slot = 2
+		}
+		return func() tea.Msg {
+			return brushChangedMsg{picked, slot}
+		}
+
+	default:
+		//
+		// A stroke starts with a press, so that's the undo step.
+		// Subsequent motion events belong to the same stroke.
+		//
+		m.pushHistory()
+		m.stamp(x, y, brush)
+	}
+	return nil
+}
+
+//
+// Where a line to (x, y) ends with ortho on or shift held: the nearest
+// point at a multiple of 45 degrees from the anchor, pulled back onto the
+// canvas if a diagonal would run off it.
+//
+func (m model) constrained(x, y int) (int, int) {
+	if (m.tool != toolLine && m.tool != toolPoly) || !m.anchorSet || !(m.ortho || m.shiftHeld) {
+		return x, y
+	}
+	dx, dy := orthoDelta(x - m.anchorX, y - m.anchorY)
+	for !m.inCanvas(m.anchorX + dx, m.anchorY + dy) {
+		dx, dy = dx - sign(dx), dy - sign(dy)
+	}
+	return m.anchorX + dx, m.anchorY + dy
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+//
+// Disarm whatever tool is active, dropping any half-drawn shape.
+//
+func (m *model) cancelTool() {
+	m.tool = toolPaint
+	m.anchorSet = false
+	m.moveMode = false
+	m.textActive = false
+	m.spraying = false
+}
+
+//
+// Called for mouse motion with a button held down.
+//
+func (m *model) drag(x, y int, brush pixel) {
+	switch m.tool {
+	case toolPaint:
+		m.stamp(x, y, brush)
+	case toolSpray:
+		if m.spraying {
+			m.sprayBurst()
+		}
+	case toolSelect, toolGradient:
+		if m.anchorSet {
+			m.selection.x1, m.selection.y1 = x, y
+		}
+	}
+}
+
+func (m *model) release() {
+	m.spraying = false
+	if m.tool == toolGradient && m.anchorSet {
+		m.pushHistory()
+		drawGradient(m.canvas(), m.selection, m.gradientRamp, m.gradientDirection, m.brushPrimary.FG)
+	}
+	if (m.tool == toolSelect || m.tool == toolGradient) && m.anchorSet {
+		m.anchorSet = false
+		m.tool = toolPaint
+	}
+}
+
+//
+// Previews are drawn in a dim gray, whatever their color, so that they're
+// easy to tell apart from what's really on the canvas.
+//
+const previewColor color = "8"
+
+//
+// What the current tool would draw if the mouse was clicked where it is
+// now, on an otherwise transparent grid.  Empty if there's nothing pending.
+//
+func (m model) overlay() Canvas {
+	overlay := canvas.NewLayer(m.width, m.height).Grid
+	switch {
+	case m.tool == toolPaste:
+		pasteRegion(overlay, m.clipboard, m.mouseX, m.mouseY, m.transparentBlanks)
+	case m.tool == toolStamp:
+		pasteRegion(overlay, m.stampBrush, m.mouseX, m.mouseY, m.transparentBlanks)
+	case m.tool.twoClick() && m.anchorSet:
+		x, y := m.constrained(m.snapped(m.mouseX, m.mouseY))
+		m.drawShape(overlay, x, y, m.brushPrimary)
+	case m.tool == toolGradient && m.anchorSet:
+		drawGradient(overlay, m.selection, m.gradientRamp, m.gradientDirection, m.brushPrimary.FG)
+	default:
+		return Canvas{}
+	}
+	return overlay
+}
+
+//
+// The canvas as it should be displayed, with the overlay on top.
+//
+func (m model) preview() Canvas {
+	canvas := canvas.Composite(m.layers, m.width, m.height)
+	overlay := m.overlay()
+	for y := range overlay.Cells() {
+		for x, p := range overlay.Cells()[y] {
+			if p != transparent && p != padding {
+				canvas.Set(x, y, pixel{R: p.R, FG: previewColor})
+			}
+		}
+	}
+	return canvas
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Mirror region left to right.  Wide glyphs stay in front of their padding.
+//
+func flipH(region [][]pixel) [][]pixel {
+	out := make([][]pixel, len(region))
+	for y, row := range region {
+		out[y] = make([]pixel, len(row))
+		for x := range row {
+			out[y][len(row) - 1 - x] = row[x]
+		}
+		for x := 0; x + 1 < len(out[y]); x++ {
+			if out[y][x] == padding && canvas.IsWide(out[y][x+1].R) {
+				out[y][x], out[y][x+1] = out[y][x+1], out[y][x]
+				x++
+			}
+		}
+	}
+	return out
+}
+
+//
+// Mirror region top to bottom.
+//
+func flipV(region [][]pixel) [][]pixel {
+	out := make([][]pixel, len(region))
+	for y, row := range region {
+		out[len(region) - 1 - y] = append([]pixel(nil), row...)
+	}
+	return out
+}
+
+//
+// Rotate a square region a quarter turn clockwise.  A wide glyph can't
+// stand on end, so it turns into a blank, as does its padding.
+//
+func rotate90(region [][]pixel) [][]pixel {
+	n := len(region)
+	out := make([][]pixel, n)
+	for y := range out {
+		out[y] = make([]pixel, n)
+	}
+	for y, row := range region {
+		for x, p := range row {
+			if p == padding || canvas.IsWide(p.R) {
+				p = p.WithRune(' ')
+			}
+			out[x][n - 1 - y] = p
+		}
+	}
+	return out
+}
+
+//
+// Replace the selected part of the active layer (all of it if nothing is
+// selected) with its transformed self.
+//
+func (m *model) transform(name string) error {
+	s := selection{0, 0, m.width - 1, m.height - 1}
+	if m.hasSelection {
+		s = m.selection.normalized()
+	}
+	s.x0, s.y0 = max(s.x0, 0), max(s.y0, 0)
+	s.x1, s.y1 = min(s.x1, m.width - 1), min(s.y1, m.height - 1)
+	region := extractRegion(m.canvas(), s)
+
+	var out [][]pixel
+	switch name {
+	case "fliph":
+		out = flipH(region)
+	case "flipv":
+		out = flipV(region)
+	case "rotate":
+		//
+		// Rotating anything but a square would change its shape, and it
+		// isn't obvious what should happen to what it then overlaps.
+		//
+		if w, h := s.x1 - s.x0 + 1, s.y1 - s.y0 + 1; w != h {
+			return fmt.Errorf("can only rotate a square, not %dx%d: select a square region first", w, h)
+		}
+		out = rotate90(region)
+	default:
+		return fmt.Errorf("unknown transform %q", name)
+	}
+
+	m.pushHistory()
+	for dy := range out {
+		for dx := range out[dy] {
+			m.canvas().Set(s.x0 + dx, s.y0 + dy, out[dy][dx])
+		}
+	}
+	return nil
+}
+
+//
+// Swap every from on the active layer for to, within the selection if
+// there is one, keeping the colors.  Returns how many were swapped.
+//
+func (m *model) replace(from, to rune) int {
+	s := selection{0, 0, m.width - 1, m.height - 1}
+	if m.hasSelection {
+		s = m.selection.normalized()
+	}
+	canvas, n := m.canvas(), 0
+	for y := s.y0; y <= s.y1; y++ {
+		for x := s.x0; x <= s.x1; x++ {
+			if p := canvas.At(x, y); p.R == from && p != transparent {
+				//
+				// Only changes get an undo step.
+				//
+				if n == 0 {
+					m.pushHistory()
+				}
+				canvas.Set(x, y, p.WithRune(to))
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name string
+		transform func([][]pixel) [][]pixel
+		in, want []string
+	}{
+		{"fliph", flipH, []string{"ab.", "cde"}, []string{".ba", "edc"}},
+		{"fliph wide", flipH, []string{"a世b"}, []string{"b世a"}},
+		{"flipv", flipV, []string{"ab", "cd", "ef"}, []string{"ef", "cd", "ab"}},
+		{"rotate", rotate90, []string{"ab", "cd"}, []string{"ca", "db"}},
+		{"rotate 3x3", rotate90, []string{"abc", "def", "ghi"}, []string{"gda", "heb", "ifc"}},
+		{"rotate wide", rotate90, []string{"世", "ab"}, []string{"a ", "b "}},
+	}
+	for _, test := range tests {
+		in := canvasOf(test.in...)
+		got := regionRows(test.transform(in.Cells()))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(regionRows(in.Cells()), test.in) {
+			t.Errorf("%s: changed its input to %q", test.name, regionRows(in.Cells()))
+		}
+	}
+}
+
+func TestTransformSelection(t *testing.T) {
+	m := testModel(3, 2)
+	m.layers[0].Grid = canvasOf("abc", "def")
+	m.selection, m.hasSelection = selection{1, 0, 2, 1}, true
+	if err := m.transform("fliph"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := regionRows(m.canvas().Cells()), []string{"acb", "dfe"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fliph: got %q, want %q", got, want)
+	}
+	m.hasSelection = false
+	if err := m.transform("flipv"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := regionRows(m.canvas().Cells()), []string{"dfe", "acb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flipv: got %q, want %q", got, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	m := testModel(4, 2)
+	m.layers[0].Grid = canvasOf("abab", "b.a ")
+	m.canvas().Set(0, 0, pixel{R: 'a', FG: "1"})
+	if n := m.replace('a', 'x'); n != 3 {
+		t.Errorf("replaced %d, want 3", n)
+	}
+	if got, want := regionRows(m.canvas().Cells()), []string{"xbxb", "b.x "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := m.canvas().At(0, 0); got != (pixel{R: 'x', FG: "1"}) {
+		t.Errorf("lost the color: got %v", got)
+	}
+
+	m.selection, m.hasSelection = selection{3, 1, 1, 0}, true
+	if n := m.replace('b', 'y'); n != 2 {
+		t.Errorf("replaced %d in the selection, want 2", n)
+	}
+	if got, want := regionRows(m.canvas().Cells()), []string{"xyxy", "b.x "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("selection: got %q, want %q", got, want)
+	}
+
+	depth := len(m.history)
+	if n := m.replace('z', 'y'); n != 0 || len(m.history) != depth {
+		t.Errorf("replacing nothing replaced %d and added %d undo steps", n, len(m.history) - depth)
+	}
+}
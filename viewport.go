@@ -0,0 +1,147 @@
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/mpenkov/gopnik/canvas"
+)
+
+//
+// Lines below the canvas: the status bar, completions and the command (or
+// status) line.  The tab bar adds one more when there are several tabs.
+//
+const statusLines = 3
+
+//
+// How far the view moves per keypress.
+//
+const panStep = 8
+
+//
+// The smallest canvas -fit makes, however small the terminal.
+//
+const (
+	minFitWidth = 20
+	minFitHeight = 10
+)
+
+//
+// The size of a canvas that fills a termWidth x termHeight terminal,
+// leaving room for the status lines.
+//
+func fitSize(termWidth, termHeight int) (width, height int) {
+	width = clamp(termWidth, minFitWidth, maxCanvasWidth)
+	height = clamp(termHeight - statusLines, minFitHeight, maxCanvasHeight)
+	return width, height
+}
+
+//
+// How much of the canvas fits in the terminal.  Until the terminal has
+// told us its size, that's all of it.
+//
+func (m model) viewSize() (width, height int) {
+	width, height = m.width, m.height
+	reserved := statusLines
+	if len(m.tabs) > 1 {
+		reserved++
+	}
+	if m.termWidth > 0 && m.termWidth < width {
+		width = m.termWidth
+	}
+	if m.termHeight > 0 && m.termHeight - reserved < height {
+		height = max(m.termHeight - reserved, 1)
+	}
+	return width, height
+}
+
+//
+// The canvas position shown in the top-left corner of the terminal.  The
+// stored offset is clamped here rather than whenever the canvas or the
+// terminal changes size.
+//
+func (m model) viewOrigin() (x, y int) {
+	width, height := m.viewSize()
+	return clamp(m.viewX, 0, m.width - width), clamp(m.viewY, 0, m.height - height)
+}
+
+func (m *model) pan(dx, dy int) {
+	x, y := m.viewOrigin()
+	m.viewX, m.viewY = x + dx, y + dy
+	m.viewX, m.viewY = m.viewOrigin()
+}
+
+//
+// Pan just enough to bring (x, y) into view.
+//
+func (m *model) scrollTo(x, y int) {
+	width, height := m.viewSize()
+	m.viewX, m.viewY = m.viewOrigin()
+	if x < m.viewX {
+		m.viewX = x
+	} else if x >= m.viewX + width {
+		m.viewX = x - width + 1
+	}
+	if y < m.viewY {
+		m.viewY = y
+	} else if y >= m.viewY + height {
+		m.viewY = y - height + 1
+	}
+	m.viewX, m.viewY = m.viewOrigin()
+}
+
+//
+// Pan so that (x, y) is in the middle of the view, or as close to it as
+// the edges of the canvas allow.
+//
+func (m *model) centerOn(x, y int) {
+	width, height := m.viewSize()
+	m.viewX, m.viewY = x - width / 2, y - height / 2
+	m.viewX, m.viewY = m.viewOrigin()
+}
+
+//
+// The canvas position under terminal cell (x, y), and whether there's any
+// canvas there at all.
+//
+func (m model) screenToCanvas(x, y int) (int, int, bool) {
+	width, height := m.viewSize()
+	originX, originY := m.viewOrigin()
+	return x + originX, y + originY, x >= 0 && y >= 0 && x < width && y < height
+}
+
+//
+// The part of canvas that's in view.  Rows are copied, so that overlays
+// can be drawn on the result.
+//
+func (m model) viewWindow(c Canvas) Canvas {
+	width, height := m.viewSize()
+	originX, originY := m.viewOrigin()
+	window := canvas.Filled(width, height, transparent)
+	for y, row := range window.Cells() {
+		copy(row, c.Cells()[originY + y][originX:])
+	}
+	return window
+}
+
+//
+// Cut line short so that it doesn't wrap, which would push the canvas up.
+//
+func (m model) fitLine(line string) string {
+	if m.termWidth <= 0 {
+		return line
+	}
+	return runewidth.Truncate(line, m.termWidth, "…")
+}
+
+//
+// Like fitLine, but keeping the end, which is where the typing happens.
+//
+func (m model) fitCommandLine(line string) string {
+	if m.termWidth <= 0 || runewidth.StringWidth(line) <= m.termWidth {
+		return line
+	}
+	runes := []rune(line)
+	for len(runes) > 0 && runewidth.StringWidth(string(runes)) > m.termWidth - 1 {
+		runes = runes[1:]
+	}
+	return "…" + string(runes)
+}
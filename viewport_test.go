@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		termWidth, termHeight, width, height int
+	}{
+		{80, 24, 80, 24 - statusLines},
+		{5, 3, minFitWidth, minFitHeight},
+		{10000, 10000, maxCanvasWidth, maxCanvasHeight},
+	}
+	for _, test := range tests {
+		if width, height := fitSize(test.termWidth, test.termHeight); width != test.width || height != test.height {
+			t.Errorf("%dx%d: got %dx%d, want %dx%d", test.termWidth, test.termHeight, width, height, test.width, test.height)
+		}
+	}
+}